@@ -0,0 +1,91 @@
+// confenc 加密配置值, 输出可以直接写入配置的ENC(...)
+//
+// 密钥是base64编码的随机密钥, 通过-gen生成, 从环境变量CONF_ENCRYPT_KEY或CONF_ENCRYPT_KEY_FILE指定的文件读取,
+// 也可以通过-key-file指定密钥文件; 不支持在命令行参数中直接传入密钥, 避免密钥出现在ps和shell历史中:
+//
+//	confenc -gen > conf.key
+//	CONF_ENCRYPT_KEY_FILE=conf.key confenc mypassword
+//	echo mypassword | confenc -key-file conf.key
+//	confenc -key-file conf.key -d 'ENC(...)'
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/kaiouz/gocomm/conf"
+)
+
+func main() {
+	keyFile := flag.String("key-file", "", "密钥文件, 默认从环境变量"+conf.EncryptKeyEnv+"或"+conf.EncryptKeyFileEnv+"读取")
+	decrypt := flag.Bool("d", false, "解密ENC(...)")
+	gen := flag.Bool("gen", false, "生成随机密钥")
+	flag.Parse()
+
+	if *gen {
+		key, err := conf.GenerateEncryptKey()
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Println(key)
+		return
+	}
+
+	var a *conf.AESGCM
+	var err error
+	if *keyFile != "" {
+		a, err = aesFromFile(*keyFile)
+	} else {
+		a, err = conf.AESGCMFromEnv()
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	// 没有参数时从标准输入逐行读取
+	values := flag.Args()
+	if len(values) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			values = append(values, scanner.Text())
+		}
+		if err = scanner.Err(); err != nil {
+			fatal(err)
+		}
+	}
+
+	for _, v := range values {
+		var out string
+		if *decrypt {
+			v = strings.TrimSuffix(strings.TrimPrefix(v, "ENC("), ")")
+			out, err = a.Decrypt(v)
+		} else {
+			out, err = a.Encrypt(v)
+		}
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Println(out)
+	}
+}
+
+func aesFromFile(file string) (*conf.AESGCM, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	key, err := conf.ParseEncryptKey(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return conf.NewAESGCM(key)
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "%v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,149 @@
+package conf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ApolloRetryInterval apollo长轮询或拉取配置失败后重试的间隔
+var ApolloRetryInterval = 5 * time.Second
+
+// apollo的通知接口在没有变化时最长hold 60秒, 客户端超时需要大于这个时间
+const apolloLongPollTimeout = 90 * time.Second
+
+type apolloNotification struct {
+	NamespaceName  string `json:"namespaceName"`
+	NotificationID int64  `json:"notificationId"`
+}
+
+// apolloSource 通过/notifications/v2长轮询监听配置发布的apollo配置源
+type apolloSource struct {
+	*MapSource
+	notifier
+	server, app, env, ns string
+	client               *http.Client
+	cacheName            string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	start  sync.Once
+}
+
+func newApolloSource(name, server, app, env, ns string, items map[string]string) *apolloSource {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &apolloSource{
+		MapSource: &MapSource{name: name, items: items},
+		server:    server,
+		app:       app,
+		env:       env,
+		ns:        ns,
+		client:    &http.Client{Timeout: apolloLongPollTimeout},
+		cacheName: name,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Watch 注册回调, 第一次注册时开始长轮询
+func (s *apolloSource) Watch(fn func(Source)) {
+	s.notifier.Watch(fn)
+	s.start.Do(func() {
+		go s.longPoll()
+	})
+}
+
+func (s *apolloSource) Close() error {
+	s.cancel()
+	return nil
+}
+
+func (s *apolloSource) longPoll() {
+	id := int64(-1)
+	for {
+		nid, changed, err := s.poll(id)
+		if s.ctx.Err() != nil {
+			return
+		}
+		if err == nil && changed {
+			if err = s.refresh(); err == nil {
+				id = nid
+			}
+		}
+		if err != nil {
+			fmt.Printf("apollo配置监听错误, %v: %v\n", s.Name(), err)
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(ApolloRetryInterval):
+			}
+		}
+	}
+}
+
+// poll 请求通知接口, 返回最新的通知id和配置是否有发布
+func (s *apolloSource) poll(id int64) (int64, bool, error) {
+	notifications, err := json.Marshal([]apolloNotification{{NamespaceName: s.ns, NotificationID: id}})
+	if err != nil {
+		return id, false, errors.WithStack(err)
+	}
+
+	q := url.Values{}
+	q.Set("appId", s.app)
+	q.Set("cluster", s.env)
+	q.Set("notifications", string(notifications))
+	u := s.server + "/notifications/v2?" + q.Encode()
+
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return id, false, errors.Wrapf(err, "apollo通知请求错误, url: %v", u)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return id, false, errors.Wrapf(err, "apollo通知请求错误, url: %v", u)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return id, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return id, false, errors.Errorf("apollo通知请求错误, url: %v, http status code: %v", u, resp.StatusCode)
+	}
+
+	var result []apolloNotification
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return id, false, errors.Wrap(err, "apollo通知json解析错误")
+	}
+	for _, n := range result {
+		if n.NamespaceName == s.ns && n.NotificationID != id {
+			return n.NotificationID, true, nil
+		}
+	}
+	return id, false, nil
+}
+
+// refresh 重新获取配置, 有变化时替换配置项并通知
+// 开始监听后第一次通知请求会立即返回, 所以使用本地缓存创建的配置源也会在这里更新为远程配置
+func (s *apolloSource) refresh() error {
+	body, err := apolloConfig(s.ctx, s.server, s.app, s.env, s.ns)
+	if err != nil {
+		return err
+	}
+	items, err := parseApollo(s.ns, body)
+	if err != nil {
+		return err
+	}
+	writeCache(s.cacheName, body)
+	changed := s.setItems(items)
+	if s.markCached(false) || changed {
+		s.notify(s)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeApollo 模拟apollo的配置接口和通知接口, 没有新的发布时通知接口等待一段时间后返回304
+type fakeApollo struct {
+	mu        sync.Mutex
+	release   int64
+	items     map[string]string
+	ids       []int64 // 通知请求带的notificationId
+	fetches   int
+	notModify int
+	changed   chan struct{}
+	down      bool // 为true时配置接口返回503
+}
+
+func newFakeApollo(items map[string]string) *fakeApollo {
+	return &fakeApollo{release: 1, items: items, changed: make(chan struct{})}
+}
+
+// publish 发布新的配置
+func (f *fakeApollo) publish(items map[string]string) {
+	f.mu.Lock()
+	f.release++
+	f.items = items
+	close(f.changed)
+	f.changed = make(chan struct{})
+	f.mu.Unlock()
+}
+
+func (f *fakeApollo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/configfiles/json/app/default/application":
+		f.mu.Lock()
+		if f.down {
+			f.mu.Unlock()
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		f.fetches++
+		items := f.items
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(items)
+	case "/notifications/v2":
+		var ns []apolloNotification
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("notifications")), &ns); err != nil || len(ns) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.ids = append(f.ids, ns[0].NotificationID)
+		release, changed := f.release, f.changed
+		f.mu.Unlock()
+
+		if ns[0].NotificationID == release {
+			select {
+			case <-changed:
+			case <-time.After(50 * time.Millisecond):
+				f.mu.Lock()
+				f.notModify++
+				f.mu.Unlock()
+				w.WriteHeader(http.StatusNotModified)
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+		f.mu.Lock()
+		release = f.release
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode([]apolloNotification{{NamespaceName: "application", NotificationID: release}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// setDown 设置配置接口是否不可用
+func (f *fakeApollo) setDown(down bool) {
+	f.mu.Lock()
+	f.down = down
+	f.mu.Unlock()
+}
+
+func (f *fakeApollo) stats() (ids []int64, fetches, notModify int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int64(nil), f.ids...), f.fetches, f.notModify
+}
+
+// waitFor 等待cond成立, 超时后测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待超时: %v", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestApolloSource(t *testing.T) {
+	fake := newFakeApollo(map[string]string{"db.host": "a"})
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	s, err := ApolloSource(srv.URL, "app", "default", "application")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewConfig()
+	c.AddLast(s)
+
+	changes := make(chan string, 10)
+	c.OnChange("db.host", func(old, new string) {
+		changes <- fmt.Sprintf("%v->%v", old, new)
+	})
+	if v := c.MustGetString("db.host"); v != "a" {
+		t.Fatalf("db.host = %q, want a", v)
+	}
+
+	// 第一次通知请求的id是-1, 立即返回当前的发布并重新获取配置, 内容没有变化不会触发回调
+	waitFor(t, "第一次通知后重新获取配置", func() bool {
+		_, fetches, _ := fake.stats()
+		return fetches >= 2
+	})
+	if ids, _, _ := fake.stats(); ids[0] != -1 {
+		t.Fatalf("第一次通知的id = %v, want -1", ids[0])
+	}
+
+	// 没有发布时通知接口返回304, 不重新获取配置
+	waitFor(t, "304", func() bool {
+		_, _, notModify := fake.stats()
+		return notModify >= 2
+	})
+	if _, fetches, _ := fake.stats(); fetches != 2 {
+		t.Fatalf("没有发布时获取了%v次配置, want 2", fetches)
+	}
+	select {
+	case ch := <-changes:
+		t.Fatalf("配置没有变化时触发了回调: %v", ch)
+	default:
+	}
+
+	// 发布后通知接口返回新的id, 重新获取配置并更新
+	fake.publish(map[string]string{"db.host": "b"})
+	select {
+	case ch := <-changes:
+		if ch != "a->b" {
+			t.Fatalf("change = %v, want a->b", ch)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("发布后没有触发回调")
+	}
+	if v := c.MustGetString("db.host"); v != "b" {
+		t.Fatalf("db.host = %q, want b", v)
+	}
+	waitFor(t, "使用新的id继续通知", func() bool {
+		ids, _, _ := fake.stats()
+		return ids[len(ids)-1] == 2
+	})
+
+	// Close后停止长轮询
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	ids, _, _ := fake.stats()
+	time.Sleep(200 * time.Millisecond)
+	if after, _, _ := fake.stats(); len(after) != len(ids) {
+		t.Fatalf("Close后继续请求通知接口: %v -> %v次", len(ids), len(after))
+	}
+}
@@ -0,0 +1,98 @@
+package conf
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Binding 绑定到配置项的值, 配置源更新后自动重新解码, 可以并发读取
+type Binding[T any] struct {
+	cfg      *Config
+	key      string
+	onChange func(old, new T)
+
+	mu     sync.Mutex
+	value  atomic.Pointer[T]
+	err    atomic.Pointer[error]
+	cancel func()
+}
+
+// BindOption Bind的选项
+type BindOption[T any] func(*Binding[T])
+
+// OnBindChange 绑定的值变化后调用fn, old和new为变化前后解码的值
+func OnBindChange[T any](fn func(old, new T)) BindOption[T] {
+	return func(b *Binding[T]) {
+		b.onChange = fn
+	}
+}
+
+// Bind 将配置项key解码到T并保持绑定, 配置源更新后重新解码
+// 新的值解码或校验失败时保留原来的值, 错误可以通过Err获取
+// 第一次解码失败时返回错误; 不再使用时需要调用Close
+func Bind[T any](c *Config, key string, opts ...BindOption[T]) (*Binding[T], error) {
+	b := &Binding[T]{cfg: c, key: key}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	// 先注册再解码, 解码期间的更新会在解码完成后重新执行
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cancel = c.subscribe(b.refresh)
+
+	v := new(T)
+	if err := c.Get(key, v); err != nil {
+		b.cancel()
+		return nil, err
+	}
+	b.value.Store(v)
+	return b, nil
+}
+
+// Load 返回最新的值
+func (b *Binding[T]) Load() T {
+	return *b.value.Load()
+}
+
+// Err 返回最近一次重新解码的错误, 成功时为nil
+func (b *Binding[T]) Err() error {
+	if err := b.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// Close 停止跟随配置更新, 之后Load返回最后的值
+func (b *Binding[T]) Close() {
+	b.cancel()
+}
+
+// refresh 配置更新后重新解码, 失败时回滚到原来的值
+func (b *Binding[T]) refresh() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	old := b.value.Load()
+	if old == nil {
+		return
+	}
+
+	v := new(T)
+	if err := b.cfg.Get(b.key, v); err != nil {
+		fmt.Printf("配置绑定更新错误, 保留原来的值, key: %v: %v\n", b.cfg.fullKey(b.key), err)
+		b.err.Store(&err)
+		return
+	}
+	b.err.Store(nil)
+
+	if reflect.DeepEqual(*old, *v) {
+		return
+	}
+	b.value.Store(v)
+	if b.onChange != nil {
+		b.onChange(*old, *v)
+	}
+}
@@ -0,0 +1,87 @@
+package conf
+
+import "testing"
+
+func TestBindRollback(t *testing.T) {
+	type server struct {
+		Host string `validate:"required"`
+		Port int    `validate:"min=1"`
+	}
+
+	c := NewConfig()
+	ps := newTestSource(map[string]string{"server.host": "h", "server.port": "80"})
+	c.AddLast(ps)
+	defer c.Close()
+
+	var changes []server
+	b, err := Bind(c, "server", OnBindChange(func(old, new server) {
+		changes = append(changes, old, new)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	publish := func(items map[string]string) {
+		ps.setItems(items)
+		ps.notify(ps)
+	}
+
+	// 校验失败时保留原来的值并记录错误, 不触发回调
+	publish(map[string]string{"server.host": "h", "server.port": "0"})
+	if v := b.Load(); v != (server{Host: "h", Port: 80}) {
+		t.Errorf("校验失败后Load() = %+v, want 原来的值", v)
+	}
+	if err := b.Err(); !Invalid(err) {
+		t.Errorf("校验失败后Err() = %v, want ValidationErr", err)
+	}
+
+	// 解码失败时同样保留原来的值
+	publish(map[string]string{"server.host": "h", "server.port": "abc"})
+	if v := b.Load(); v != (server{Host: "h", Port: 80}) {
+		t.Errorf("解码失败后Load() = %+v, want 原来的值", v)
+	}
+	if err := b.Err(); err == nil || Invalid(err) {
+		t.Errorf("解码失败后Err() = %v, want 解码错误", err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("更新失败时触发了回调: %+v", changes)
+	}
+
+	// 之后合法的更新清除错误并触发回调
+	publish(map[string]string{"server.host": "h2", "server.port": "81"})
+	if v := b.Load(); v != (server{Host: "h2", Port: 81}) {
+		t.Errorf("Load() = %+v, want {h2 81}", v)
+	}
+	if err := b.Err(); err != nil {
+		t.Errorf("合法的更新后Err() = %v, want nil", err)
+	}
+	want := []server{{Host: "h", Port: 80}, {Host: "h2", Port: 81}}
+	if len(changes) != 2 || changes[0] != want[0] || changes[1] != want[1] {
+		t.Errorf("回调 = %+v, want %+v", changes, want)
+	}
+
+	// 值没有变化时不触发回调
+	publish(map[string]string{"server.host": "h2", "server.port": "81", "other": "x"})
+	if len(changes) != 2 {
+		t.Errorf("值没有变化时触发了回调: %+v", changes)
+	}
+
+	// Close后不再更新
+	b.Close()
+	publish(map[string]string{"server.host": "h3", "server.port": "82"})
+	if v := b.Load(); v.Host != "h2" {
+		t.Errorf("Close后Load() = %+v, want 最后的值", v)
+	}
+}
+
+func TestBindInitialError(t *testing.T) {
+	type server struct {
+		Port int `validate:"required"`
+	}
+	c := NewConfig()
+	c.AddLast(&MapSource{name: "m", items: map[string]string{"server.host": "h"}})
+	if _, err := Bind[server](c, "server"); !Invalid(err) {
+		t.Errorf("Bind() = %v, want ValidationErr", err)
+	}
+}
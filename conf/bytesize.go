@@ -0,0 +1,105 @@
+package conf
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ByteSize 字节大小, 配置值可以带单位, 例如512MB, 1.5GiB, 单位按1024换算
+type ByteSize uint64
+
+const (
+	Byte ByteSize = 1 << (10 * iota)
+	KB
+	MB
+	GB
+	TB
+	PB
+)
+
+var byteSizeType = reflect.TypeOf(ByteSize(0))
+
+var byteSizeUnits = []struct {
+	name string
+	size ByteSize
+}{
+	{"PB", PB}, {"TB", TB}, {"GB", GB}, {"MB", MB}, {"KB", KB},
+}
+
+// ParseByteSize 解析带单位的字节大小, 单位不区分大小写, 支持B, K/KB/KiB, M/MB/MiB, G/GB/GiB, T/TB/TiB, P/PB/PiB
+func ParseByteSize(s string) (ByteSize, error) {
+	str := strings.TrimSpace(s)
+	i := len(str)
+	for i > 0 && (str[i-1] < '0' || str[i-1] > '9') && str[i-1] != '.' {
+		i--
+	}
+	num, unit := strings.TrimSpace(str[:i]), strings.ToUpper(strings.TrimSpace(str[i:]))
+
+	// KiB的i只能跟在单位字母后面
+	prefix := strings.TrimSuffix(unit, "B")
+	if len(prefix) == 2 && prefix[1] == 'I' {
+		prefix = prefix[:1]
+	}
+
+	var size ByteSize
+	switch prefix {
+	case "":
+		size = Byte
+	case "K":
+		size = KB
+	case "M":
+		size = MB
+	case "G":
+		size = GB
+	case "T":
+		size = TB
+	case "P":
+		size = PB
+	default:
+		return 0, errors.Errorf("invalid byte size: %s", s)
+	}
+
+	// 没有小数时按整数解析, 避免大数丢失精度
+	if u, err := strconv.ParseUint(num, 10, 64); err == nil {
+		if u > math.MaxUint64/uint64(size) {
+			return 0, errors.Errorf("byte size overflows uint64: %s", s)
+		}
+		return ByteSize(u) * size, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, errors.Errorf("invalid byte size: %s", s)
+	}
+	// float64(math.MaxUint64)等于2^64, 大于等于它的值无法转换
+	if v := f * float64(size); v >= float64(math.MaxUint64) {
+		return 0, errors.Errorf("byte size overflows uint64: %s", s)
+	}
+	return ByteSize(f * float64(size)), nil
+}
+
+// String 返回带单位的字节大小, 例如512MB
+func (b ByteSize) String() string {
+	for _, u := range byteSizeUnits {
+		if b >= u.size && b%u.size == 0 {
+			return strconv.FormatUint(uint64(b/u.size), 10) + u.name
+		}
+	}
+	return strconv.FormatUint(uint64(b), 10) + "B"
+}
+
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	size, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
+
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
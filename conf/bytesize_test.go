@@ -0,0 +1,75 @@
+package conf
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ByteSize
+		wantErr bool
+	}{
+		{in: "0", want: 0},
+		{in: "512", want: 512},
+		{in: "512B", want: 512},
+		{in: "1K", want: KB},
+		{in: "1kb", want: KB},
+		{in: "1KiB", want: KB},
+		{in: "1ki", want: KB},
+		{in: " 512 MB ", want: 512 * MB},
+		{in: "1.5GiB", want: GB + GB/2},
+		{in: "2T", want: 2 * TB},
+		{in: "3PB", want: 3 * PB},
+		{in: "16383PB", want: 16383 * PB},
+		{in: "18446744073709551615", want: 18446744073709551615},
+		{in: ".5K", want: 512},
+
+		{in: "", wantErr: true},
+		{in: "MB", wantErr: true},
+		{in: "-1MB", wantErr: true},
+		{in: "1XB", wantErr: true},
+		{in: "10iB", wantErr: true},
+		{in: "10i", wantErr: true},
+		{in: "1BB", wantErr: true},
+		{in: "1.2.3MB", wantErr: true},
+		{in: "16384PB", wantErr: true},
+		{in: "20000PB", wantErr: true},
+		{in: "18446744073709551616", wantErr: true},
+		{in: "1e30GB", wantErr: true},
+		{in: "16384.0PB", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseByteSize(%q) = %v, want error", tt.in, uint64(got))
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseByteSize(%q) = %v, %v, want %v", tt.in, uint64(got), err, uint64(tt.want))
+		}
+	}
+}
+
+func TestByteSizeString(t *testing.T) {
+	tests := []struct {
+		in   ByteSize
+		want string
+	}{
+		{0, "0B"},
+		{100, "100B"},
+		{KB, "1KB"},
+		{1536, "1536B"},
+		{512 * MB, "512MB"},
+		{3 * PB, "3PB"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("ByteSize(%d).String() = %q, want %q", uint64(tt.in), got, tt.want)
+		}
+		var b ByteSize
+		if err := b.UnmarshalText([]byte(tt.want)); err != nil || b != tt.in {
+			t.Errorf("UnmarshalText(%q) = %v, %v, want %v", tt.want, uint64(b), err, uint64(tt.in))
+		}
+	}
+}
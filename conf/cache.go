@@ -0,0 +1,120 @@
+package conf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// 内容来自本地缓存的配置源名称的后缀
+const cachedSuffix = "(cached)"
+
+// remoteCache 远程配置源(apollo, nacos)的本地缓存设置
+var remoteCache struct {
+	mu                   sync.RWMutex
+	dir                  string
+	staleWhileRevalidate bool
+}
+
+// SetCacheDir 设置远程配置源的本地缓存目录, 为空时不缓存
+// 每次成功获取远程配置后写入缓存, 远程配置获取失败时使用缓存的内容创建配置源, 配置源名称带(cached)后缀
+func SetCacheDir(dir string) {
+	remoteCache.mu.Lock()
+	remoteCache.dir = dir
+	remoteCache.mu.Unlock()
+}
+
+// SetStaleWhileRevalidate 开启后, 有本地缓存时直接使用缓存的内容创建远程配置源, 在后台获取远程配置并更新
+func SetStaleWhileRevalidate(enable bool) {
+	remoteCache.mu.Lock()
+	remoteCache.staleWhileRevalidate = enable
+	remoteCache.mu.Unlock()
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// cacheFile 返回配置源对应的缓存文件, 没有设置缓存目录时返回空字符串
+func cacheFile(name string) string {
+	remoteCache.mu.RLock()
+	dir := remoteCache.dir
+	remoteCache.mu.RUnlock()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, unsafeFileChars.ReplaceAllString(name, "_")+".cache")
+}
+
+func readCache(name string) ([]byte, bool) {
+	file := cacheFile(name)
+	if file == "" {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// writeCache 写入缓存, 先写临时文件再重命名, 避免进程退出时留下不完整的缓存
+func writeCache(name string, data []byte) {
+	file := cacheFile(name)
+	if file == "" {
+		return
+	}
+	err := os.MkdirAll(filepath.Dir(file), 0755)
+	if err == nil {
+		tmp := file + ".tmp"
+		if err = ioutil.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, file)
+		}
+	}
+	if err != nil {
+		fmt.Printf("配置缓存写入错误, %v: %v\n", name, errors.WithStack(err))
+	}
+}
+
+// loadRemote 获取远程配置的内容, 成功时写入缓存, 失败时使用缓存, 第二个返回值表示内容是否来自缓存
+// 开启了stale-while-revalidate并且有缓存时直接返回缓存, 由调用方在后台更新
+func loadRemote(name string, fetch func() ([]byte, error)) ([]byte, bool, error) {
+	remoteCache.mu.RLock()
+	swr := remoteCache.staleWhileRevalidate
+	remoteCache.mu.RUnlock()
+
+	if swr {
+		if data, ok := readCache(name); ok {
+			return data, true, nil
+		}
+	}
+
+	data, err := fetch()
+	if err != nil {
+		cached, ok := readCache(name)
+		if !ok {
+			return nil, false, err
+		}
+		fmt.Printf("远程配置获取错误, 使用本地缓存, %v: %v\n", name, err)
+		return cached, true, nil
+	}
+
+	writeCache(name, data)
+	return data, false, nil
+}
+
+// markCached 标记配置源的内容是否来自本地缓存, 来自缓存时名称带(cached)后缀, 返回名称是否有变化
+func (s *MapSource) markCached(cached bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.name
+	s.name = strings.TrimSuffix(s.name, cachedSuffix)
+	if cached {
+		s.name += cachedSuffix
+	}
+	return s.name != old
+}
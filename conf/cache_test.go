@@ -0,0 +1,124 @@
+package conf
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const apolloTestName = "apollo-app-default-application"
+
+// withCache 在测试期间使用临时的缓存目录
+func withCache(t *testing.T, swr bool) {
+	interval := ApolloRetryInterval
+	ApolloRetryInterval = 10 * time.Millisecond
+	SetCacheDir(t.TempDir())
+	SetStaleWhileRevalidate(swr)
+	t.Cleanup(func() {
+		SetCacheDir("")
+		SetStaleWhileRevalidate(false)
+		ApolloRetryInterval = interval
+	})
+}
+
+func TestRemoteCacheFallback(t *testing.T) {
+	withCache(t, false)
+	fake := newFakeApollo(map[string]string{"db.host": "a"})
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	// 成功获取时写入缓存
+	s, err := ApolloSource(srv.URL, "app", "default", "application")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name() != apolloTestName {
+		t.Fatalf("Name() = %q, want %q", s.Name(), apolloTestName)
+	}
+	s.(*apolloSource).Close()
+
+	// 获取失败时使用缓存, 名称带(cached)后缀
+	fake.setDown(true)
+	fake.publish(map[string]string{"db.host": "b"})
+	s, err = ApolloSource(srv.URL, "app", "default", "application")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name() != apolloTestName+cachedSuffix {
+		t.Fatalf("Name() = %q, want %q", s.Name(), apolloTestName+cachedSuffix)
+	}
+
+	c := NewConfig()
+	c.AddLast(s)
+	defer c.Close()
+	if v := c.MustGetString("db.host"); v != "a" {
+		t.Fatalf("db.host = %q, want 缓存的值a", v)
+	}
+	changes := make(chan string, 10)
+	c.OnChange("db.host", func(old, new string) {
+		changes <- fmt.Sprintf("%v->%v", old, new)
+	})
+
+	// 远程恢复后更新配置并去掉(cached)后缀
+	fake.setDown(false)
+	select {
+	case ch := <-changes:
+		if ch != "a->b" {
+			t.Fatalf("change = %v, want a->b", ch)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("远程恢复后没有更新")
+	}
+	waitFor(t, "去掉(cached)后缀", func() bool {
+		return s.Name() == apolloTestName
+	})
+}
+
+func TestRemoteCacheMissing(t *testing.T) {
+	withCache(t, false)
+	fake := newFakeApollo(map[string]string{"db.host": "a"})
+	fake.setDown(true)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	if _, err := ApolloSource(srv.URL, "app", "default", "application"); err == nil {
+		t.Fatal("没有缓存时获取失败应返回错误")
+	}
+}
+
+func TestRemoteCacheStaleWhileRevalidate(t *testing.T) {
+	withCache(t, true)
+	writeCache(apolloTestName, []byte(`{"db.host": "cached"}`))
+
+	fake := newFakeApollo(map[string]string{"db.host": "remote"})
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	// 有缓存时直接使用缓存, 不请求配置接口
+	s, err := ApolloSource(srv.URL, "app", "default", "application")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, fetches, _ := fake.stats(); fetches != 0 {
+		t.Fatalf("stale-while-revalidate时获取了%v次配置, want 0", fetches)
+	}
+	if s.Name() != apolloTestName+cachedSuffix {
+		t.Fatalf("Name() = %q, want %q", s.Name(), apolloTestName+cachedSuffix)
+	}
+
+	// 开始监听后在后台更新为远程的配置
+	c := NewConfig()
+	c.AddLast(s)
+	defer c.Close()
+	if v := c.MustGetString("db.host"); v != "cached" {
+		t.Fatalf("db.host = %q, want cached", v)
+	}
+	waitFor(t, "后台更新", func() bool {
+		v, _ := c.GetString("db.host")
+		return v == "remote" && s.Name() == apolloTestName
+	})
+	if data, ok := readCache(apolloTestName); !ok || string(data) != `{"db.host":"remote"}`+"\n" {
+		t.Errorf("更新后的缓存 = %q", data)
+	}
+}
@@ -1,11 +1,17 @@
 package conf
 
 import (
+	"encoding"
 	"fmt"
 	"github.com/pkg/errors"
+	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // 配置项不存在错误
@@ -23,24 +29,257 @@ func NotFound(err error) bool {
 	return ok
 }
 
-// 配置
+// 配置, 可以并发读取, 读取配置项时不加锁, 总是基于一个不可变的快照
 type Config struct {
-	sources []Source
+	// 当前快照, *snapshot, 添加配置源或配置源更新时替换
+	state   atomic.Value
+	writeMu sync.Mutex
+
+	// Sub创建的视图指向原配置, 配置项的key都加上prefix
+	root   *Config
+	prefix string
+
+	decryptor Decryptor
+	profiles  []string
+
+	mu          sync.Mutex
+	fireMu      sync.Mutex
+	listeners   []*changeListener
+	subscribers []*func()
+
+	// 待调用的回调, 同一时间只有一个goroutine依次调用, 回调中可以再添加配置源
+	queueMu  sync.Mutex
+	queue    []func()
+	draining bool
+}
+
+// changeListener 配置项变化的监听
+type changeListener struct {
+	key   string
+	value string
+	fn    func(old, new string)
 }
 
 // 添加一个配置源，最高优先级
 func (c *Config) AddFirst(source Source) {
-	// 扩容
-	c.sources = append(c.sources, source)
-	// 右移
-	copy(c.sources[1:], c.sources[0:])
-	// 设置
-	c.sources[0] = source
+	if c.root != nil {
+		c.root.AddFirst(source)
+		return
+	}
+
+	c.writeMu.Lock()
+	old := c.load().sources
+	sources := make([]Source, 0, len(old)+1)
+	sources = append(sources, source)
+	sources = append(sources, old...)
+	c.state.Store(newSnapshot(sources))
+	c.writeMu.Unlock()
+
+	c.watch(source)
 }
 
 // 添加一个配置源，最低优先级
 func (c *Config) AddLast(source Source) {
-	c.sources = append(c.sources, source)
+	if c.root != nil {
+		c.root.AddLast(source)
+		return
+	}
+
+	c.writeMu.Lock()
+	old := c.load().sources
+	sources := make([]Source, 0, len(old)+1)
+	sources = append(sources, old...)
+	sources = append(sources, source)
+	c.state.Store(newSnapshot(sources))
+	c.writeMu.Unlock()
+
+	c.watch(source)
+}
+
+// watch 监听可变化的配置源, 配置源更新后发布新的快照, 并触发已注册的配置项监听
+func (c *Config) watch(source Source) {
+	if ws, ok := source.(WatchableSource); ok {
+		ws.Watch(func(Source) {
+			c.publish()
+			c.fireChange()
+		})
+	}
+	c.fireChange()
+}
+
+// OnChange 监听配置项的变化, 配置源更新或添加新的配置源导致配置项的值变化时调用fn
+// 配置项不存在时值为空字符串; fn中可以添加配置源, 添加后触发的回调在fn返回后调用
+func (c *Config) OnChange(key string, fn func(old, new string)) {
+	v, _ := c.GetString(key)
+
+	b := c.base()
+	b.mu.Lock()
+	b.listeners = append(b.listeners, &changeListener{key: c.fullKey(key), value: v, fn: fn})
+	b.mu.Unlock()
+}
+
+// fireChange 检查监听的配置项, 对值变化的配置项调用回调
+// 回调在锁外按检查的顺序调用, 回调中添加配置源或修改配置触发的检查会排在当前回调之后调用, 不会死锁
+func (c *Config) fireChange() {
+	c.fireMu.Lock()
+
+	type change struct {
+		l        *changeListener
+		old, new string
+	}
+
+	var changes []change
+	c.mu.Lock()
+	for _, l := range c.listeners {
+		v, _ := c.GetString(l.key)
+		if v != l.value {
+			changes = append(changes, change{l: l, old: l.value, new: v})
+			l.value = v
+		}
+	}
+	subscribers := make([]*func(), len(c.subscribers))
+	copy(subscribers, c.subscribers)
+	c.mu.Unlock()
+
+	// 在fireMu内入队, 保证回调的顺序与检查的顺序一致
+	drain := c.enqueue(func() {
+		for _, ch := range changes {
+			ch.l.fn(ch.old, ch.new)
+		}
+		for _, fn := range subscribers {
+			(*fn)()
+		}
+	})
+	c.fireMu.Unlock()
+
+	if drain {
+		c.dispatch()
+	}
+}
+
+// enqueue 添加待调用的回调, 返回是否需要由当前goroutine调用dispatch
+func (c *Config) enqueue(fn func()) bool {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+	c.queue = append(c.queue, fn)
+	if c.draining {
+		return false
+	}
+	c.draining = true
+	return true
+}
+
+// dispatch 依次调用队列中的回调直到队列为空
+func (c *Config) dispatch() {
+	defer func() {
+		// 回调panic时允许之后的fireChange继续调用剩余的回调
+		if r := recover(); r != nil {
+			c.queueMu.Lock()
+			c.draining = false
+			c.queueMu.Unlock()
+			panic(r)
+		}
+	}()
+
+	for {
+		c.queueMu.Lock()
+		if len(c.queue) == 0 {
+			c.draining = false
+			c.queueMu.Unlock()
+			return
+		}
+		fn := c.queue[0]
+		c.queue[0] = nil
+		c.queue = c.queue[1:]
+		c.queueMu.Unlock()
+
+		fn()
+	}
+}
+
+// subscribe 注册配置更新后的回调, 在fireChange中与配置项监听一起依次调用
+// 返回取消注册的函数
+func (c *Config) subscribe(fn func()) func() {
+	b := c.base()
+	p := &fn
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, p)
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, s := range b.subscribers {
+			if s == p {
+				b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Close 停止监听所有可变化的配置源
+func (c *Config) Close() error {
+	var rerr error
+	for _, s := range c.base().load().sources {
+		if ws, ok := s.(WatchableSource); ok {
+			if err := ws.Close(); err != nil && rerr == nil {
+				rerr = err
+			}
+		}
+	}
+	return rerr
+}
+
+// SetDecryptor 设置解密ENC(...)配置值的Decryptor, 需要在读取配置前设置
+// 没有设置时使用AESGCMFromEnv创建的AES-GCM解密
+func (c *Config) SetDecryptor(d Decryptor) {
+	b := c.base()
+	b.decryptor = d
+	// 丢弃已缓存的解密结果
+	b.publish()
+}
+
+// Sub 返回以prefix为前缀的子配置视图, 例如cfg.Sub("redis").GetString("host")等价于cfg.GetString("redis.host")
+// 子配置与原配置共享配置源, 原配置添加或更新配置源后子配置也能获取到最新的值
+func (c *Config) Sub(prefix string) *Config {
+	return &Config{root: c.base(), prefix: c.fullKey(prefix)}
+}
+
+// Keys 返回全部配置项的key, 已排序, Sub视图返回的是相对于前缀的key
+// 只能列出实现了KeysSource的配置源中的配置项
+func (c *Config) Keys() []string {
+	all := c.base().load().keys()
+	if c.prefix == "" {
+		return append([]string(nil), all...)
+	}
+
+	var keys []string
+	for _, k := range keysWithPrefix(all, c.prefix) {
+		k = k[len(c.prefix):]
+		if strings.HasPrefix(k, ".") {
+			k = k[1:]
+		} else if !strings.HasPrefix(k, "[") {
+			continue
+		}
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// base 返回持有配置源的配置
+func (c *Config) base() *Config {
+	if c.root != nil {
+		return c.root
+	}
+	return c
+}
+
+// fullKey 返回加上视图前缀的key
+func (c *Config) fullKey(key string) string {
+	return joinKey(c.prefix, key)
 }
 
 // AddCommandLineSource 添加命令行的配置
@@ -48,6 +287,12 @@ func (c *Config) AddCommandLineSource() {
 	c.AddLast(CMDLineSource())
 }
 
+// AddEnvSource 添加环境变量的配置, 环境变量的映射规则见EnvSource
+// 环境变量需要覆盖配置文件时, 应在添加文件配置之前调用
+func (c *Config) AddEnvSource(prefix string) {
+	c.AddLast(EnvSource(prefix))
+}
+
 // AddNacosSource 添加nacos配置
 func (c *Config) AddNacosSource(nacosUrl, namespaceId, dataId, group, username, password string) error {
 	source, err := NacosSource(nacosUrl, namespaceId, dataId, group, username, password)
@@ -60,12 +305,23 @@ func (c *Config) AddNacosSource(nacosUrl, namespaceId, dataId, group, username,
 
 // AddNacosSourceFromConfig 从配置中获取参数添加nacos的配置
 func (c *Config) AddNacosSourceFromConfig() error {
-	dataId := c.GetStringDefault("nacos.dataId", "")
-	namespace := c.GetStringDefault("nacos.namespaceId", "")
-	nacosUrl := c.GetStringDefault("nacos.url", "")
-	group := c.GetStringDefault("nacos.group", "DEFAULT_GROUP")
-	username := c.GetStringDefault("nacos.username", "")
-	password := c.GetStringDefault("nacos.password", "")
+	var err error
+	get := func(key, val string) string {
+		v, e := c.getStringOr(key, val)
+		if err == nil {
+			err = e
+		}
+		return v
+	}
+	dataId := get("nacos.dataId", "")
+	namespace := get("nacos.namespaceId", "")
+	nacosUrl := get("nacos.url", "")
+	group := get("nacos.group", "DEFAULT_GROUP")
+	username := get("nacos.username", "")
+	password := get("nacos.password", "")
+	if err != nil {
+		return err
+	}
 
 	if nacosUrl == "" || namespace == "" || dataId == "" {
 		fmt.Println("did not load nacos config source,  because not found nacos params from config")
@@ -76,49 +332,116 @@ func (c *Config) AddNacosSourceFromConfig() error {
 }
 
 // AddFileSource 添加文件配置
+// 有激活的profile时, 同时添加同目录下的name-{profile}.ext文件, 优先级高于file, 后面的profile优先级更高,
+// 不存在的profile文件会被忽略; 之前的配置源和环境变量中没有profile时, 使用file中的profile配置项,
+// 否则file中的profile不会生效, 例如部署时通过CONF_PROFILE选择的profile优先于file中的默认值
 func (c *Config) AddFileSource(file string) error {
-	source, err := FileSource(file)
+	return c.addFileSource(osFS{}, file)
+}
 
+// AddFileSourceFromConfig 从配置中获取参数添加文件配置
+func (c *Config) AddFileSourceFromConfig() error {
+	file, err := c.getStringOr("config.file", "")
 	if err != nil {
 		return err
 	}
+	if file == "" {
+		fmt.Println("did not load file config source, because not found config.file from config")
+		return nil
+	}
+	return c.AddFileSource(file)
+}
 
+// AddDirSource 添加目录配置, 最低优先级, 按文件名顺序合并目录下的配置文件, 见DirSource
+func (c *Config) AddDirSource(dir string) error {
+	source, err := DirSourceWithProfiles(dir, c.Profiles())
+	if err != nil {
+		return err
+	}
 	c.AddLast(source)
 	return nil
 }
 
-// AddFileSourceFromConfig 从配置中获取参数添加文件配置
-func (c *Config) AddFileSourceFromConfig() error {
-	file := c.GetStringDefault("config.file", "")
-	if file == "" {
-		fmt.Println("did not load file config source, because not found config.file from config")
+// AddKeyPerFileSource 添加每个文件一个配置项的目录配置, 最低优先级, 见KeyPerFileSource
+func (c *Config) AddKeyPerFileSource(dir string) error {
+	source, err := KeyPerFileSource(dir)
+	if err != nil {
+		return err
+	}
+	c.AddLast(source)
+	return nil
+}
+
+// AddDirSourceFromConfig 从配置中获取参数添加目录配置
+// config.dir为目录, config.keyPerFile为true时按每个文件一个配置项加载
+func (c *Config) AddDirSourceFromConfig() error {
+	dir, err := c.getStringOr("config.dir", "")
+	if err != nil {
+		return err
+	}
+	if dir == "" {
+		fmt.Println("did not load dir config source, because not found config.dir from config")
 		return nil
 	}
-	return c.AddFileSource(file)
+	keyPerFile, err := c.GetBoolDefault("config.keyPerFile", false)
+	if err != nil {
+		return err
+	}
+	if keyPerFile {
+		return c.AddKeyPerFileSource(dir)
+	}
+	return c.AddDirSource(dir)
 }
 
-// 获取配置项的值, 不存在配置项则返回零值和NotFoundErr, error只可能是nil或NotFoundErr
+// 获取配置项的值, 不存在配置项则返回零值和NotFoundErr, 显式配置为空字符串或YAML的null时返回空字符串
+// 值中的${key}和${key:default}占位符会被替换, 占位符无法解析或循环引用时返回错误, 语法见resolve
+// ENC(...)形式的值会被解密, 解密后的值不再替换占位符
 func (c *Config) GetString(key string) (string, error) {
-	for _, s := range c.sources {
-		v := s.Get(key)
-		if v != "" {
-			return v, nil
+	return cachedValue(c, "string", key, func() (string, error) {
+		key = c.fullKey(key)
+		v, err := c.base().lookup(key)
+		if err != nil {
+			return "", err
 		}
+		return c.base().expand(key, v, []string{key})
+	})
+}
+
+// expand 处理配置项的原始值, 解密ENC(...)或者替换占位符
+func (c *Config) expand(key, v string, stack []string) (string, error) {
+	if ciphertext, ok := isEncrypted(v); ok {
+		return c.decrypt(key, ciphertext)
+	}
+	return c.resolve(v, stack)
+}
+
+// lookup 按优先级从配置源获取配置项的原始值
+func (c *Config) lookup(key string) (string, error) {
+	if v, ok := c.load().lookup(key); ok {
+		return v, nil
 	}
 	return "", NotFoundErr{key: key}
 }
 
 // 获取配置项的值, 不存在配置项则返回第二个参数
+// 占位符无法解析或解密失败等其他错误会panic, 需要处理这些错误时使用ValueOr
 func (c *Config) GetStringDefault(key string, val string) string {
-	v, err := c.GetString(key)
-	// 如果有错误，这个错误只可能是NotFoundErr
-	// 在这里如果没有找到的话就返回默认值
+	v, err := c.getStringOr(key, val)
 	if err != nil {
-		return val
+		panic(fmt.Sprintf("config err: %v\n%+v", key, err))
 	}
 	return v
 }
 
+// getStringOr 获取配置项的值, 不存在配置项则返回val和nil, 其他错误原样返回
+func (c *Config) getStringOr(key string, val string) (string, error) {
+	v, err := c.GetString(key)
+	if err != nil && NotFound(err) {
+		return val, nil
+	}
+	return v, err
+}
+
 // 获取配置项的值, 与GetString一样，除了遇到错误会panic
 func (c *Config) MustGetString(key string) string {
 	v, err := c.GetString(key)
@@ -128,17 +451,28 @@ func (c *Config) MustGetString(key string) string {
 	return v
 }
 
-// 获取bool类型的配置项, 不存在配置项则返回零值和NotFoundErr
-func (c *Config) GetBool(key string) (bool, error) {
+// getValue 获取非字符串类型配置项的值, 显式配置为空的配置项当作没有配置, 返回NotFoundErr
+func (c *Config) getValue(key string) (string, error) {
 	s, err := c.GetString(key)
-	if err != nil {
-		return false, err
+	if err == nil && s == "" {
+		return "", NotFoundErr{key: c.fullKey(key)}
 	}
-	v, err := strconv.ParseBool(s)
-	if err != nil {
-		return false, errors.Wrapf(err, "prop value: %s with key: %s is not bool value", s, key)
-	}
-	return v, nil
+	return s, err
+}
+
+// 获取bool类型的配置项, 不存在配置项则返回零值和NotFoundErr
+func (c *Config) GetBool(key string) (bool, error) {
+	return cachedValue(c, "bool", key, func() (bool, error) {
+		s, err := c.getValue(key)
+		if err != nil {
+			return false, err
+		}
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return false, errors.Wrapf(err, "prop value: %s with key: %s is not bool value", s, key)
+		}
+		return v, nil
+	})
 }
 
 // 获取bool类型的配置项, 不存在配置项则返回第二个参数并且error==nil
@@ -185,15 +519,17 @@ func (c *Config) MustGetInt(key string) int {
 
 // 获取int64类型的配置项,不存在配置项则返回零值和NotFoundErr
 func (c *Config) GetInt64(key string) (int64, error) {
-	s, err := c.GetString(key)
-	if err != nil {
-		return 0, err
-	}
-	v, err := strconv.ParseInt(s, 10, 64)
-	if err != nil {
-		return 0, errors.Wrapf(err, "prop value: %s with key: %s is not int value", s, key)
-	}
-	return v, nil
+	return cachedValue(c, "int64", key, func() (int64, error) {
+		s, err := c.getValue(key)
+		if err != nil {
+			return 0, err
+		}
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "prop value: %s with key: %s is not int value", s, key)
+		}
+		return v, nil
+	})
 }
 
 // 获取int64类型的配置项,不存在配置项则返回第二个参数并且error==nil
@@ -216,22 +552,24 @@ func (c *Config) MustGetInt64(key string) int64 {
 
 // 获取uint64类型的配置项,不存在配置项则返回零值和NotFoundErr
 func (c *Config) GetUint64(key string) (uint64, error) {
-	s, err := c.GetString(key)
-	if err != nil {
-		return 0, err
-	}
-	v, err := strconv.ParseUint(s, 10, 64)
-	if err != nil {
-		return 0, errors.Wrapf(err, "prop value: %s with key: %s is not uint value", s, key)
-	}
-	return v, nil
+	return cachedValue(c, "uint64", key, func() (uint64, error) {
+		s, err := c.getValue(key)
+		if err != nil {
+			return 0, err
+		}
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "prop value: %s with key: %s is not uint value", s, key)
+		}
+		return v, nil
+	})
 }
 
 // 获取uint64类型的配置项,不存在配置项则返回第二个参数并且error==nil
 func (c *Config) GetUint64Default(key string, val uint64) (uint64, error) {
 	u, err := c.GetUint64(key)
 	if err != nil && NotFound(err) {
-		return val, err
+		return val, nil
 	}
 	return u, err
 }
@@ -247,15 +585,17 @@ func (c *Config) MustGetUint64(key string) uint64 {
 
 // 获取float64类型的配置项,不存在配置项则返回零值和NotFoundErr
 func (c *Config) GetFloat64(key string) (float64, error) {
-	s, err := c.GetString(key)
-	if err != nil {
-		return 0, err
-	}
-	v, err := strconv.ParseFloat(s, 64)
-	if err != nil {
-		return 0, errors.Wrapf(err, "prop value: %s with key: %s is not float value", s, key)
-	}
-	return v, nil
+	return cachedValue(c, "float64", key, func() (float64, error) {
+		s, err := c.getValue(key)
+		if err != nil {
+			return 0, err
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "prop value: %s with key: %s is not float value", s, key)
+		}
+		return v, nil
+	})
 }
 
 // 获取float64类型的配置项,不存在配置项则返回第二个参数并且error==nil
@@ -276,6 +616,105 @@ func (c *Config) MustGetFloat64(key string) float64 {
 	return f
 }
 
+// 获取time.Duration类型的配置项, 配置值的格式如30s, 1h30m, 不存在配置项则返回零值和NotFoundErr
+func (c *Config) GetDuration(key string) (time.Duration, error) {
+	return cachedValue(c, "duration", key, func() (time.Duration, error) {
+		s, err := c.getValue(key)
+		if err != nil {
+			return 0, err
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, errors.Wrapf(err, "prop value: %s with key: %s is not duration value", s, key)
+		}
+		return d, nil
+	})
+}
+
+// 获取time.Duration类型的配置项,不存在配置项则返回第二个参数并且error==nil
+func (c *Config) GetDurationDefault(key string, val time.Duration) (time.Duration, error) {
+	d, err := c.GetDuration(key)
+	if err != nil && NotFound(err) {
+		return val, nil
+	}
+	return d, err
+}
+
+// 获取time.Duration类型的配置项，与GetDuration一样，除了遇到错误会panic
+func (c *Config) MustGetDuration(key string) time.Duration {
+	d, err := c.GetDuration(key)
+	if err != nil {
+		panic(fmt.Sprintf("config err: %v\n%+v", key, err))
+	}
+	return d
+}
+
+// 获取time.Time类型的配置项, 配置值为RFC3339格式, 不存在配置项则返回零值和NotFoundErr
+func (c *Config) GetTime(key string) (time.Time, error) {
+	return cachedValue(c, "time", key, func() (time.Time, error) {
+		s, err := c.getValue(key)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return time.Time{}, errors.Wrapf(err, "prop value: %s with key: %s is not RFC3339 time value", s, key)
+		}
+		return t, nil
+	})
+}
+
+// 获取time.Time类型的配置项,不存在配置项则返回第二个参数并且error==nil
+func (c *Config) GetTimeDefault(key string, val time.Time) (time.Time, error) {
+	t, err := c.GetTime(key)
+	if err != nil && NotFound(err) {
+		return val, nil
+	}
+	return t, err
+}
+
+// 获取time.Time类型的配置项，与GetTime一样，除了遇到错误会panic
+func (c *Config) MustGetTime(key string) time.Time {
+	t, err := c.GetTime(key)
+	if err != nil {
+		panic(fmt.Sprintf("config err: %v\n%+v", key, err))
+	}
+	return t
+}
+
+// 获取ByteSize类型的配置项, 配置值的格式如512MB, 不存在配置项则返回零值和NotFoundErr
+func (c *Config) GetByteSize(key string) (ByteSize, error) {
+	return cachedValue(c, "bytesize", key, func() (ByteSize, error) {
+		s, err := c.getValue(key)
+		if err != nil {
+			return 0, err
+		}
+		b, err := ParseByteSize(s)
+		if err != nil {
+			return 0, errors.Wrapf(err, "prop value: %s with key: %s is not byte size value", s, key)
+		}
+		return b, nil
+	})
+}
+
+// 获取ByteSize类型的配置项,不存在配置项则返回第二个参数并且error==nil
+func (c *Config) GetByteSizeDefault(key string, val ByteSize) (ByteSize, error) {
+	b, err := c.GetByteSize(key)
+	if err != nil && NotFound(err) {
+		return val, nil
+	}
+	return b, err
+}
+
+// 获取ByteSize类型的配置项，与GetByteSize一样，除了遇到错误会panic
+func (c *Config) MustGetByteSize(key string) ByteSize {
+	b, err := c.GetByteSize(key)
+	if err != nil {
+		panic(fmt.Sprintf("config err: %v\n%+v", key, err))
+	}
+	return b
+}
+
 // 获取[]string类型的配置项，不存在配置项则返回零值和NotFoundErr
 func (c *Config) GetSliceString(key string) ([]string, error) {
 	var v []string
@@ -316,7 +755,27 @@ func (c *Config) Get(key string, v interface{}) error {
 	return c.get(key, rv.Elem())
 }
 
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	urlType             = reflect.TypeOf(url.URL{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
 func (c *Config) get(key string, v reflect.Value) error {
+	// 需要特殊转换的类型, 优先于按kind转换
+	switch v.Type() {
+	case durationType:
+		return c.getDuration(key, v)
+	case timeType:
+		return c.getTime(key, v)
+	case urlType:
+		return c.getURL(key, v)
+	}
+	if isTextUnmarshaler(v) {
+		return c.getText(key, v)
+	}
+
 	switch v.Kind() {
 	case reflect.Bool:
 		return c.getBool(key, v)
@@ -390,6 +849,54 @@ func (c *Config) getString(key string, v reflect.Value) error {
 	return nil
 }
 
+func (c *Config) getDuration(key string, v reflect.Value) error {
+	d, err := c.GetDuration(key)
+	if err != nil {
+		return err
+	}
+	v.SetInt(int64(d))
+	return nil
+}
+
+func (c *Config) getTime(key string, v reflect.Value) error {
+	t, err := c.GetTime(key)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+func (c *Config) getURL(key string, v reflect.Value) error {
+	s, err := c.getValue(key)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return errors.Wrapf(err, "prop value: %s with key: %s is not url value", s, key)
+	}
+	v.Set(reflect.ValueOf(*u))
+	return nil
+}
+
+// isTextUnmarshaler 是否可以通过encoding.TextUnmarshaler转换, 例如net.IP和ByteSize
+func isTextUnmarshaler(v reflect.Value) bool {
+	return v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType)
+}
+
+func (c *Config) getText(key string, v reflect.Value) error {
+	s, err := c.getValue(key)
+	if err != nil {
+		return err
+	}
+	err = v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	if err != nil {
+		return errors.Wrapf(err, "prop value: %s with key: %s 转换为%v错误", s, key, v.Type())
+	}
+	return nil
+}
+
 func (c *Config) getArray(key string, v reflect.Value) error {
 	// 长度为0的数组,不用获取值
 	len := v.Len()
@@ -398,8 +905,10 @@ func (c *Config) getArray(key string, v reflect.Value) error {
 	}
 
 	var rerr error = NotFoundErr{key: key}
+	var violations []Violation
 	for i := 0; i < len; i++ {
 		err := c.get(fmt.Sprintf("%s[%d]", key, i), v.Index(i))
+		err = collectViolations(&violations, err, true)
 		if err != nil && !NotFound(err) {
 			return err
 		}
@@ -407,7 +916,7 @@ func (c *Config) getArray(key string, v reflect.Value) error {
 			rerr = nil
 		}
 	}
-	return rerr
+	return violationsOr(violations, rerr)
 }
 
 func (c *Config) getInterface(key string, v reflect.Value) error {
@@ -454,10 +963,11 @@ func (c *Config) getSlice(key string, v reflect.Value) error {
 
 	eleTyp := v.Type().Elem()
 	var nfe error = NotFoundErr{key: key}
+	var violations []Violation
 	for i := 0; ; i++ {
 		ele := reflect.New(eleTyp).Elem()
 		err := c.get(fmt.Sprintf("%s[%d]", key, i), ele)
-		if err != nil {
+		if err = collectViolations(&violations, err, false); err != nil {
 			if NotFound(err) {
 				break
 			}
@@ -472,15 +982,114 @@ func (c *Config) getSlice(key string, v reflect.Value) error {
 		v.Set(sv)
 	}
 
-	return nfe
+	return violationsOr(violations, nfe)
 }
 
+// getMap 绑定map, map的key是配置项key的下一级名称, 支持string, 数字和实现了encoding.TextUnmarshaler的key
 func (c *Config) getMap(key string, v reflect.Value) error {
-	return errors.New("目前不支持map")
+	typ := v.Type()
+	names := c.childKeys(key)
+
+	mv := v
+	if v.IsNil() {
+		mv = reflect.MakeMapWithSize(typ, len(names))
+	}
+
+	var nfe error = NotFoundErr{key: key}
+	var violations []Violation
+	for _, name := range names {
+		kv := reflect.New(typ.Key()).Elem()
+		if err := setMapKey(kv, name); err != nil {
+			return errors.Wrapf(err, "map key: %s with key: %s 转换错误", name, key)
+		}
+
+		ev := reflect.New(typ.Elem()).Elem()
+		err := c.get(joinKey(key, name), ev)
+		if err = collectViolations(&violations, err, false); err != nil {
+			if NotFound(err) {
+				continue
+			}
+			return err
+		}
+		nfe = nil
+		mv.SetMapIndex(kv, ev)
+	}
+
+	if nfe == nil && v.IsNil() {
+		v.Set(mv)
+	}
+
+	return violationsOr(violations, nfe)
+}
+
+func setMapKey(v reflect.Value, name string) error {
+	if isTextUnmarshaler(v) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(name))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(name)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(name, 10, v.Type().Bits())
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(name, 10, v.Type().Bits())
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		v.SetUint(u)
+	default:
+		return errors.Errorf("不支持的map key类型: %v", v.Type())
+	}
+	return nil
+}
+
+// childKeys 返回key的下一级配置项名称, 例如key为db时, db.host和db.slaves[0]对应host和slaves
+// 只能列出实现了KeysSource的配置源中的配置项
+func (c *Config) childKeys(key string) []string {
+	key = c.fullKey(key)
+	prefix := ""
+	if key != "" {
+		prefix = key + "."
+	}
+
+	var names []string
+	seen := map[string]bool{}
+	for _, k := range keysWithPrefix(c.base().load().keys(), prefix) {
+		rest := k[len(prefix):]
+		if i := strings.IndexAny(rest, ".["); i >= 0 {
+			rest = rest[:i]
+		}
+		if rest != "" && !seen[rest] {
+			seen[rest] = true
+			names = append(names, rest)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// joinKey 拼接配置项的key, name是下标时不加.
+func joinKey(key, name string) string {
+	if key == "" {
+		return name
+	}
+	if name == "" {
+		return key
+	}
+	if name[0] == '[' {
+		return key + name
+	}
+	return key + "." + name
 }
 
 func (c *Config) getStruct(key string, v reflect.Value) error {
 	var nfe error = NotFoundErr{key: key}
+	var violations []Violation
 
 	typ := v.Type()
 	for i := 0; i < typ.NumField(); i++ {
@@ -503,21 +1112,53 @@ func (c *Config) getStruct(key string, v reflect.Value) error {
 				s := f.Name
 				prop = strings.ToLower(s[:1]) + s[1:]
 			}
-			if k != "" {
-				k += "."
-			}
-			k += prop
+			k = joinKey(k, prop)
 		}
 		err := c.get(k, fv)
+		// 没有配置项时使用default标签的值
+		if def, ok := f.Tag.Lookup("default"); ok && NotFound(err) {
+			err = setDefault(k, def, fv)
+		}
+		err = collectViolations(&violations, err, true)
 		if err != nil && !NotFound(err) {
 			return err
 		}
 		if err == nil {
 			nfe = nil
 		}
+		// 校验字段
+		if rules := f.Tag.Get("validate"); rules != "" {
+			vs, err := validateField(c.fullKey(k), rules, fv, err == nil)
+			if err != nil {
+				return err
+			}
+			violations = append(violations, vs...)
+		}
 	}
 
-	return nfe
+	return violationsOr(violations, nfe)
+}
+
+// setDefault 把default标签的值设置到字段, 转换规则与配置项相同
+// slice和array类型的默认值用,分隔, 例如default:"a,b,c"
+func setDefault(key, def string, v reflect.Value) error {
+	items := map[string]string{}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i, s := range strings.Split(def, ",") {
+			items[fmt.Sprintf("%s[%d]", key, i)] = strings.TrimSpace(s)
+		}
+	default:
+		items[key] = def
+	}
+
+	dc := NewConfig()
+	dc.AddLast(&MapSource{name: "default", items: items})
+	err := dc.get(key, v)
+	if err != nil && !NotFound(err) {
+		return errors.Wrapf(err, "default值: %s with key: %s 错误", def, key)
+	}
+	return err
 }
 
 // NewConfig 创建配置
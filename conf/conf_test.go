@@ -0,0 +1,84 @@
+package conf
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestSource 创建只能手动更新的可监听配置源, 通过setItems和notify模拟配置发布
+func newTestSource(items map[string]string) *pollSource {
+	s := newPollSource("test", items)
+	s.interval = time.Hour
+	s.reload = func() (map[string]string, bool, error) {
+		return nil, false, nil
+	}
+	return s
+}
+
+func TestOnChangeAddSourceInCallback(t *testing.T) {
+	c := NewConfig()
+	ps := newTestSource(map[string]string{"dataId": "a"})
+	c.AddLast(ps)
+	defer c.Close()
+
+	var got []string
+	c.OnChange("extra", func(old, new string) {
+		got = append(got, new)
+	})
+	c.OnChange("dataId", func(old, new string) {
+		c.AddLast(&MapSource{name: "m", items: map[string]string{"extra": new}})
+	})
+
+	done := make(chan struct{})
+	go func() {
+		ps.setItems(map[string]string{"dataId": "b"})
+		ps.notify(ps)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("在OnChange回调中添加配置源死锁")
+	}
+
+	if v := c.MustGetString("extra"); v != "b" {
+		t.Fatalf("extra = %q, want b", v)
+	}
+	if len(got) != 1 || got[0] != "b" {
+		t.Fatalf("extra的回调 = %v, want [b]", got)
+	}
+}
+
+func TestBindChangeAddSourceInCallback(t *testing.T) {
+	type server struct {
+		Port int `conf:"port"`
+	}
+
+	c := NewConfig()
+	ps := newTestSource(map[string]string{"server.port": "80"})
+	c.AddLast(ps)
+	defer c.Close()
+
+	b, err := Bind(c, "server", OnBindChange(func(old, new server) {
+		c.AddLast(&MapSource{name: "m", items: map[string]string{"other": "1"}})
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	done := make(chan struct{})
+	go func() {
+		ps.setItems(map[string]string{"server.port": "81"})
+		ps.notify(ps)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("在OnBindChange回调中添加配置源死锁")
+	}
+	if b.Load().Port != 81 {
+		t.Fatalf("port = %v, want 81", b.Load().Port)
+	}
+}
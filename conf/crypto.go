@@ -0,0 +1,172 @@
+package conf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// EncryptKeyEnv 内置解密器读取密钥的环境变量
+	EncryptKeyEnv = "CONF_ENCRYPT_KEY"
+	// EncryptKeyFileEnv 内置解密器读取密钥文件路径的环境变量, 没有设置EncryptKeyEnv时使用
+	EncryptKeyFileEnv = "CONF_ENCRYPT_KEY_FILE"
+)
+
+// Decryptor 解密ENC(...)形式的配置值
+type Decryptor interface {
+	Decrypt(ciphertext string) (string, error)
+}
+
+// DecryptorFunc 函数形式的Decryptor
+type DecryptorFunc func(ciphertext string) (string, error)
+
+func (f DecryptorFunc) Decrypt(ciphertext string) (string, error) {
+	return f(ciphertext)
+}
+
+// isEncrypted 是否为ENC(...)形式的加密值, 返回括号中的密文
+func isEncrypted(v string) (string, bool) {
+	if strings.HasPrefix(v, "ENC(") && strings.HasSuffix(v, ")") {
+		return v[4 : len(v)-1], true
+	}
+	return "", false
+}
+
+// AESGCM 内置的AES-GCM加解密, 密文格式为base64(nonce + 密文)
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM 创建AES-GCM加解密, key是16, 24或32字节的密钥
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &AESGCM{aead: aead}, nil
+}
+
+// ParseEncryptKey 解析base64编码的16, 24或32字节的随机密钥, 可以通过GenerateEncryptKey生成
+// 密文保存在配置中心等地方, 不支持口令作为密钥, 避免弱口令被离线穷举
+func ParseEncryptKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errors.New("密钥必须是base64编码的16, 24或32字节的随机密钥")
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	}
+	return nil, errors.Errorf("密钥长度错误: %v字节, 必须是16, 24或32字节", len(key))
+}
+
+// GenerateEncryptKey 生成base64编码的32字节随机密钥
+func GenerateEncryptKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// newAESGCMFromKey 使用ParseEncryptKey解析的密钥创建AES-GCM加解密
+func newAESGCMFromKey(s string) (*AESGCM, error) {
+	key, err := ParseEncryptKey(s)
+	if err != nil {
+		return nil, err
+	}
+	return NewAESGCM(key)
+}
+
+// AESGCMFromEnv 从环境变量CONF_ENCRYPT_KEY或CONF_ENCRYPT_KEY_FILE指定的文件读取密钥创建AES-GCM加解密
+func AESGCMFromEnv() (*AESGCM, error) {
+	if key := os.Getenv(EncryptKeyEnv); key != "" {
+		a, err := newAESGCMFromKey(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "环境变量%s的密钥错误", EncryptKeyEnv)
+		}
+		return a, nil
+	}
+	if file := os.Getenv(EncryptKeyFileEnv); file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "读取密钥文件错误, file: %v", file)
+		}
+		a, err := newAESGCMFromKey(string(data))
+		if err != nil {
+			return nil, errors.Wrapf(err, "密钥文件的密钥错误, file: %v", file)
+		}
+		return a, nil
+	}
+	return nil, errors.Errorf("没有配置密钥, 需要设置环境变量%s或%s", EncryptKeyEnv, EncryptKeyFileEnv)
+}
+
+// Encrypt 加密, 返回ENC(...)形式的配置值
+func (a *AESGCM) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", errors.WithStack(err)
+	}
+	sealed := a.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return "ENC(" + base64.StdEncoding.EncodeToString(sealed) + ")", nil
+}
+
+// Decrypt 解密ENC(...)括号中的密文
+func (a *AESGCM) Decrypt(ciphertext string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", errors.Wrap(err, "密文base64解码错误")
+	}
+	n := a.aead.NonceSize()
+	if len(data) < n {
+		return "", errors.New("密文长度错误")
+	}
+	plain, err := a.aead.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return "", errors.Wrap(err, "解密错误")
+	}
+	return string(plain), nil
+}
+
+var envDecryptor struct {
+	once sync.Once
+	d    Decryptor
+	err  error
+}
+
+// defaultDecryptor 没有设置Decryptor时使用从环境变量读取密钥的AES-GCM
+func defaultDecryptor() (Decryptor, error) {
+	envDecryptor.once.Do(func() {
+		envDecryptor.d, envDecryptor.err = AESGCMFromEnv()
+	})
+	return envDecryptor.d, envDecryptor.err
+}
+
+// decrypt 解密配置项key的密文
+func (c *Config) decrypt(key, ciphertext string) (string, error) {
+	d := c.decryptor
+	if d == nil {
+		var err error
+		if d, err = defaultDecryptor(); err != nil {
+			return "", errors.Wrapf(err, "配置项解密错误, key: %s", key)
+		}
+	}
+	v, err := d.Decrypt(ciphertext)
+	if err != nil {
+		return "", errors.Wrapf(err, "配置项解密错误, key: %s", key)
+	}
+	return v, nil
+}
@@ -0,0 +1,85 @@
+package conf
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestParseEncryptKey(t *testing.T) {
+	key := func(n int) string {
+		return base64.StdEncoding.EncodeToString(make([]byte, n))
+	}
+	tests := []struct {
+		in      string
+		wantLen int
+		wantErr bool
+	}{
+		{in: key(16), wantLen: 16},
+		{in: key(24), wantLen: 24},
+		{in: " " + key(32) + "\n", wantLen: 32},
+		{in: key(8), wantErr: true},
+		{in: key(33), wantErr: true},
+		{in: "hunter2", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseEncryptKey(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseEncryptKey(%q) want error", tt.in)
+			}
+			continue
+		}
+		if err != nil || len(got) != tt.wantLen {
+			t.Errorf("ParseEncryptKey(%q) = %v bytes, %v, want %v bytes", tt.in, len(got), err, tt.wantLen)
+		}
+	}
+}
+
+func TestAESGCMRoundTrip(t *testing.T) {
+	k, err := GenerateEncryptKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseEncryptKey(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAESGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := a.Encrypt("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, ok := isEncrypted(enc)
+	if !ok {
+		t.Fatalf("Encrypt = %q, want ENC(...)", enc)
+	}
+	if plain, err := a.Decrypt(ct); err != nil || plain != "s3cret" {
+		t.Fatalf("Decrypt = %q, %v", plain, err)
+	}
+
+	k2, _ := GenerateEncryptKey()
+	if k2 == k {
+		t.Fatal("GenerateEncryptKey生成了相同的密钥")
+	}
+	key2, _ := ParseEncryptKey(k2)
+	other, _ := NewAESGCM(key2)
+	if _, err := other.Decrypt(ct); err == nil {
+		t.Fatal("使用其他密钥解密成功")
+	}
+	if _, err := a.Decrypt(ct[:8]); err == nil {
+		t.Fatal("截断的密文解密成功")
+	}
+}
+
+func TestAESGCMFromEnvRejectsPassphrase(t *testing.T) {
+	t.Setenv(EncryptKeyEnv, "hunter2")
+	if _, err := AESGCMFromEnv(); err == nil || !strings.Contains(err.Error(), EncryptKeyEnv) {
+		t.Fatalf("AESGCMFromEnv() error = %v, want error mentioning %v", err, EncryptKeyEnv)
+	}
+}
@@ -0,0 +1,141 @@
+package conf
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 目录配置源合并的配置文件后缀
+var dirExts = map[string]bool{".yaml": true, ".yml": true, ".json": true, ".toml": true, ".properties": true}
+
+// dirFiles 返回目录下按文件名排序的文件, 跳过子目录和.开头的文件,
+// 例如kubernetes挂载ConfigMap时的..data目录; 符号链接按指向的文件处理
+// 第二个返回值是文件名, 修改时间和大小组成的指纹, 用于判断目录内容是否变化
+func dirFiles(fsys fs.FS, dir string, accept func(name string) bool) ([]string, string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "读取目录配置错误")
+	}
+
+	var files []string
+	var fp strings.Builder
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") || !accept(name) {
+			continue
+		}
+		file := joinPath(fsys, dir, name)
+		info, err := fs.Stat(fsys, file)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "读取目录配置错误")
+		}
+		if info.IsDir() {
+			continue
+		}
+		files = append(files, file)
+		fmt.Fprintf(&fp, "%s|%d|%d\n", name, info.ModTime().UnixNano(), info.Size())
+	}
+	sort.Strings(files)
+	return files, fp.String(), nil
+}
+
+// DirSource 创建目录配置源, 按文件名顺序合并目录下的.yaml, .yml, .json, .toml和.properties文件,
+// 后面的文件覆盖前面的, 适用于conf.d形式的配置片段
+// 返回的配置源实现了WatchableSource, 目录下的文件增删或修改, 以及include的文件修改后会重新加载
+func DirSource(dir string) (Source, error) {
+	return DirSourceWithProfiles(dir, nil)
+}
+
+// DirSourceWithProfiles 创建目录配置源, YAML文件中带on-profile的文档按profiles激活
+func DirSourceWithProfiles(dir string, profiles []string) (Source, error) {
+	return newDirSource(osFS{}, "dir:"+dir, dir, profiles)
+}
+
+// newDirSource 创建fsys中合并目录下配置文件的配置源
+func newDirSource(fsys fs.FS, name, dir string, profiles []string) (Source, error) {
+	accept := func(name string) bool {
+		return dirExts[strings.ToLower(filepath.Ext(name))]
+	}
+	load := func(files []string) (map[string]string, map[string]time.Time, error) {
+		items := map[string]string{}
+		modTimes := map[string]time.Time{}
+		for _, file := range files {
+			fi, mts, err := loadFile(fsys, file, profiles)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "目录配置文件错误: %v", file)
+			}
+			mergeItems(items, fi)
+			for f, mt := range mts {
+				modTimes[f] = mt
+			}
+		}
+		return items, modTimes, nil
+	}
+	return newPollDirSource(fsys, name, dir, accept, load)
+}
+
+// KeyPerFileSource 创建每个文件一个配置项的目录配置源, 文件名是配置项的key, 去掉末尾换行的文件内容是值,
+// 适用于kubernetes按key挂载的ConfigMap和Secret
+// 返回的配置源实现了WatchableSource, 目录下的文件增删或修改后会重新加载
+func KeyPerFileSource(dir string) (Source, error) {
+	return newKeyPerFileSource(osFS{}, "keyPerFile:"+dir, dir)
+}
+
+// newKeyPerFileSource 创建fsys中每个文件一个配置项的目录配置源
+func newKeyPerFileSource(fsys fs.FS, name, dir string) (Source, error) {
+	accept := func(string) bool {
+		return true
+	}
+	load := func(files []string) (map[string]string, map[string]time.Time, error) {
+		items := map[string]string{}
+		for _, file := range files {
+			data, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "读取目录配置错误")
+			}
+			items[baseName(fsys, file)] = strings.TrimRight(string(data), "\r\n")
+		}
+		return items, nil, nil
+	}
+	return newPollDirSource(fsys, name, dir, accept, load)
+}
+
+// newPollDirSource 创建轮询目录的配置源, accept选择目录下的文件, load加载选择的文件,
+// 并返回需要额外检查修改时间的文件, 例如目录外include的文件
+func newPollDirSource(fsys fs.FS, name, dir string, accept func(string) bool, load func([]string) (map[string]string, map[string]time.Time, error)) (Source, error) {
+	files, fp, err := dirFiles(fsys, dir, accept)
+	if err != nil {
+		return nil, err
+	}
+	items, modTimes, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newPollSource(name, items)
+	s.reload = func() (map[string]string, bool, error) {
+		files, nfp, err := dirFiles(fsys, dir, accept)
+		if err != nil {
+			return nil, false, err
+		}
+		if nfp == fp {
+			changed, err := modTimesChanged(fsys, modTimes)
+			if err != nil || !changed {
+				return nil, false, err
+			}
+		}
+		items, mts, err := load(files)
+		if err != nil {
+			return nil, false, err
+		}
+		fp, modTimes = nfp, mts
+		return items, true, nil
+	}
+	return s, nil
+}
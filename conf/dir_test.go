@@ -0,0 +1,137 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDirSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub.yaml"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{
+		"10-base.yaml":       "a: base\nb: base\nc: base\nlist: [1, 2]\n",
+		"20-db.json":         `{"b": "json", "db": {"host": "h"}}`,
+		"30-last.properties": "c=props\n",
+		"README.txt":         "a: txt\n",
+		".hidden.yaml":       "a: hidden\n",
+		"sub.yaml/x.yaml":    "a: sub\n",
+	})
+
+	s, err := DirSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a": "base", "b": "json", "c": "props", "db.host": "h", "list[0]": "1", "list[1]": "2"}
+	if got := mapOf(s.(*pollSource).MapSource); !reflect.DeepEqual(got, want) {
+		t.Errorf("DirSource() = %v, want %v", got, want)
+	}
+
+	if _, err := DirSource(filepath.Join(dir, "missing")); err == nil {
+		t.Error("目录不存在时应返回错误")
+	}
+	writeFiles(t, dir, map[string]string{"40-bad.json": "{"})
+	if _, err := DirSource(dir); err == nil {
+		t.Error("文件格式错误时应返回错误")
+	}
+}
+
+func TestDirSourceReload(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "conf.d")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	shared := filepath.Join(root, "shared.yaml")
+	writeFiles(t, root, map[string]string{"shared.yaml": "s: 1\n"})
+	writeFiles(t, dir, map[string]string{"a.yaml": "include: ../shared.yaml\na: 1\n"})
+
+	s, err := DirSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := s.(*pollSource)
+	if _, changed, err := ps.reload(); changed || err != nil {
+		t.Fatalf("没有修改时reload() = %v, %v", changed, err)
+	}
+
+	// 目录外include的文件修改后重新加载
+	writeFiles(t, root, map[string]string{"shared.yaml": "s: 2\n"})
+	touch(t, shared)
+	items, changed, err := ps.reload()
+	if err != nil || !changed || items["s"] != "2" || items["a"] != "1" {
+		t.Fatalf("include的文件修改后reload() = %v, %v, %v", items, changed, err)
+	}
+	if _, changed, _ := ps.reload(); changed {
+		t.Fatal("重新加载后没有修改时不应再次加载")
+	}
+
+	// 目录下新增文件
+	writeFiles(t, dir, map[string]string{"b.yaml": "a: 2\n"})
+	items, changed, err = ps.reload()
+	if err != nil || !changed || items["a"] != "2" {
+		t.Fatalf("新增文件后reload() = %v, %v, %v", items, changed, err)
+	}
+}
+
+func TestKeyPerFileSource(t *testing.T) {
+	// kubernetes挂载的布局: key是指向..data/key的符号链接, ..data指向带时间戳的目录
+	dir := t.TempDir()
+	data := filepath.Join(dir, "..2024_01_01")
+	if err := os.Mkdir(data, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, data, map[string]string{
+		"db.password": "secret\n",
+		"db.user":     "root",
+		"tls.crt":     "line1\nline2\r\n",
+	})
+	if err := os.Symlink(filepath.Base(data), filepath.Join(dir, "..data")); err != nil {
+		t.Skip("不支持符号链接:", err)
+	}
+	for _, key := range []string{"db.password", "db.user", "tls.crt"} {
+		if err := os.Symlink(filepath.Join("..data", key), filepath.Join(dir, key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := KeyPerFileSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"db.password": "secret", "db.user": "root", "tls.crt": "line1\nline2"}
+	if got := mapOf(s.(*pollSource).MapSource); !reflect.DeepEqual(got, want) {
+		t.Errorf("KeyPerFileSource() = %v, want %v", got, want)
+	}
+
+	// 更新时kubernetes写入新的目录并切换..data
+	data2 := filepath.Join(dir, "..2024_01_02")
+	if err := os.Mkdir(data2, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, data2, map[string]string{"db.password": "new", "db.user": "root", "tls.crt": "crt"})
+	touch(t, filepath.Join(data2, "db.password"))
+	if err := os.Remove(filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Base(data2), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	items, changed, err := s.(*pollSource).reload()
+	if err != nil || !changed || items["db.password"] != "new" {
+		t.Fatalf("切换..data后reload() = %v, %v, %v", items, changed, err)
+	}
+}
+
+// touch 修改文件的修改时间, 避免文件系统的时间精度导致修改时间不变
+func touch(t *testing.T, file string) {
+	t.Helper()
+	mt := time.Now().Add(time.Hour)
+	if err := os.Chtimes(file, mt, mt); err != nil {
+		t.Fatal(err)
+	}
+}
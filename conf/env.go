@@ -0,0 +1,80 @@
+package conf
+
+import (
+	"os"
+	"strings"
+)
+
+// envSource 环境变量配置源, 配置项的key不区分大小写
+type envSource struct {
+	*MapSource
+}
+
+func (s *envSource) Lookup(key string) (string, bool) {
+	return s.MapSource.Lookup(strings.ToLower(key))
+}
+
+func (s *envSource) Get(key string) string {
+	v, _ := s.Lookup(key)
+	return v
+}
+
+// EnvSource 创建环境变量配置源
+// 只读取以prefix_开头的环境变量, 去掉前缀后按_分割, 纯数字的部分作为下标,
+// 例如prefix为APP时, APP_DB_HOST对应db.host, APP_SERVERS_0对应servers[0]
+// 环境变量无法表达大小写, 所以配置项的key不区分大小写, 例如APP_NACOS_DATAID对应nacos.dataId;
+// Config.Keys等列出配置项时按其它配置源中的写法, 所以也可以覆盖map中驼峰形式的key
+func EnvSource(prefix string) Source {
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+
+	items := map[string]string{}
+	for _, env := range os.Environ() {
+		i := strings.IndexByte(env, '=')
+		if i <= 0 {
+			continue
+		}
+		name, value := env[:i], env[i+1:]
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if key := envKey(name[len(prefix):]); key != "" {
+			items[key] = value
+		}
+	}
+
+	return &envSource{MapSource: &MapSource{name: "env:" + prefix, items: items}}
+}
+
+// envKey 把DB_HOST, SERVERS_0形式的变量名转换成db.host, servers[0]形式的key
+func envKey(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(strings.ToLower(name), "_") {
+		if part == "" {
+			// 连续的_或者以_开头/结尾的变量名无法对应key
+			return ""
+		}
+		if isDigits(part) {
+			if b.Len() == 0 {
+				return ""
+			}
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,72 @@
+package conf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvKey(t *testing.T) {
+	tests := map[string]string{
+		"DB_HOST":        "db.host",
+		"NACOS_DATAID":   "nacos.dataid",
+		"SERVERS_0":      "servers[0]",
+		"SERVERS_0_HOST": "servers[0].host",
+		"M_1_2":          "m[1][2]",
+		"0_A":            "",
+		"A__B":           "",
+		"_A":             "",
+		"A_":             "",
+	}
+	for name, want := range tests {
+		if got := envKey(name); got != want {
+			t.Errorf("envKey(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestEnvSourceOverridesCamelCase(t *testing.T) {
+	t.Setenv("CONFTEST_NACOS_DATAID", "env-id")
+	t.Setenv("CONFTEST_UPSTREAMS_MYSERVICE_HOST", "env-host")
+	t.Setenv("CONFTEST_UPSTREAMS_MYSERVICE_PORT", "9090")
+	t.Setenv("CONFTEST_UPSTREAMS_NEWSERVICE_HOST", "new-host")
+
+	c := NewConfig()
+	c.AddEnvSource("CONFTEST")
+	c.AddLast(&MapSource{name: "yaml", items: map[string]string{
+		"nacos.dataId":             "yaml-id",
+		"upstreams.myService.host": "yaml-host",
+		"upstreams.other.host":     "other-host",
+	}})
+
+	if v := c.MustGetString("nacos.dataId"); v != "env-id" {
+		t.Errorf("nacos.dataId = %q, want env-id", v)
+	}
+
+	wantKeys := []string{
+		"nacos.dataId",
+		"upstreams.myService.host",
+		"upstreams.myService.port",
+		"upstreams.newservice.host",
+		"upstreams.other.host",
+	}
+	if keys := c.Keys(); !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("Keys() = %q, want %q", keys, wantKeys)
+	}
+
+	type upstream struct {
+		Host string
+		Port int
+	}
+	var upstreams map[string]upstream
+	if err := c.Get("upstreams", &upstreams); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]upstream{
+		"myService":  {Host: "env-host", Port: 9090},
+		"newservice": {Host: "new-host"},
+		"other":      {Host: "other-host"},
+	}
+	if !reflect.DeepEqual(upstreams, want) {
+		t.Errorf("upstreams = %+v, want %+v", upstreams, want)
+	}
+}
@@ -0,0 +1,136 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SensitiveWords key中包含这些词(不区分大小写)的配置项在Dump中会被隐藏
+var SensitiveWords = []string{"password", "passwd", "pwd", "secret", "token", "credential", "accesskey", "privatekey", "apikey"}
+
+const redacted = "******"
+
+// SourceValue 配置源中配置项的值
+type SourceValue struct {
+	Source string
+	Value  string
+}
+
+// Explanation 配置项的来源
+type Explanation struct {
+	// Key 完整的key
+	Key string
+	// Value 生效的原始值, 没有替换占位符和解密
+	Value string
+	// Source 生效的配置源名称
+	Source string
+	// Shadowed 被覆盖的低优先级配置源中的值, 按优先级从高到低排列
+	Shadowed []SourceValue
+}
+
+// Explain 返回配置项的值来自哪个配置源, 以及被覆盖的值, 不存在配置项则返回NotFoundErr
+func (c *Config) Explain(key string) (Explanation, error) {
+	key = c.fullKey(key)
+	e := Explanation{Key: key}
+	found := false
+	for _, s := range c.base().load().frozen {
+		v, ok := s.Lookup(key)
+		if !ok {
+			continue
+		}
+		if !found {
+			e.Value, e.Source, found = v, s.Name(), true
+			continue
+		}
+		e.Shadowed = append(e.Shadowed, SourceValue{Source: s.Name(), Value: v})
+	}
+	if !found {
+		return e, NotFoundErr{key: key}
+	}
+	return e, nil
+}
+
+// Dump 返回合并后的全部配置, 每行一个配置项, 格式为key = value  # 配置源, 按key排序
+// 值是替换占位符后的值, 敏感配置项, ENC(...)加密的值, 以及占位符直接或间接引用了这些配置项的值会被隐藏,
+// 可以用于调试和启动日志
+// 只能列出实现了KeysSource的配置源中的配置项
+func (c *Config) Dump() string {
+	var b strings.Builder
+	for _, k := range c.Keys() {
+		e, err := c.Explain(k)
+		if err != nil {
+			continue
+		}
+
+		var v string
+		if _, ok := isEncrypted(e.Value); ok || sensitive(e.Key) || c.base().revealsSecret(e.Value, map[string]bool{e.Key: true}) {
+			v = redacted
+		} else if v, err = c.GetString(k); err != nil {
+			v = fmt.Sprintf("%s (%v)", e.Value, err)
+		}
+
+		fmt.Fprintf(&b, "%s = %s  # %s", k, v, e.Source)
+		if len(e.Shadowed) > 0 {
+			names := make([]string, len(e.Shadowed))
+			for i, sv := range e.Shadowed {
+				names[i] = sv.Source
+			}
+			fmt.Fprintf(&b, ", shadows %s", strings.Join(names, ", "))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// revealsSecret value中的占位符是否引用了敏感配置项或ENC(...)加密的值, 包括间接引用和默认值中的占位符,
+// 替换后的值可能包含敏感信息; seen是已经检查过的key
+func (c *Config) revealsSecret(value string, seen map[string]bool) bool {
+	for i := 0; i < len(value); {
+		if strings.HasPrefix(value[i:], "$${") {
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(value[i:], "${") {
+			i++
+			continue
+		}
+		end := placeholderEnd(value, i+2)
+		if end < 0 {
+			return false
+		}
+
+		expr := value[i+2 : end]
+		name, def := expr, ""
+		if j := defaultSep(expr); j >= 0 {
+			name, def = expr[:j], expr[j+1:]
+		}
+		name = strings.TrimSpace(name)
+		if sensitive(name) {
+			return true
+		}
+		if !seen[name] {
+			seen[name] = true
+			if v, err := c.lookup(name); err == nil {
+				if _, ok := isEncrypted(v); ok || c.revealsSecret(v, seen) {
+					return true
+				}
+			}
+		}
+		if c.revealsSecret(def, seen) {
+			return true
+		}
+		i = end + 1
+	}
+	return false
+}
+
+// sensitive 配置项是否为敏感配置
+func sensitive(key string) bool {
+	k := strings.ToLower(key)
+	for _, w := range SensitiveWords {
+		if strings.Contains(k, w) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package conf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDumpRedactsResolvedSecrets(t *testing.T) {
+	c := NewConfig()
+	c.AddLast(&MapSource{name: "m", items: map[string]string{
+		"db.password":  "hunter2",
+		"db.url":       "jdbc://u:${db.password}@h",
+		"db.cipher":    "ENC(AAAA)",
+		"db.dsn":       "u:${db.cipher}@h",
+		"app.pw.alias": "${db.password}",
+		"app.indirect": "x-${app.link}",
+		"app.link":     "${db.url}",
+		"app.default":  "${missing.key:${db.password}}",
+		"app.escaped":  "$${db.password}",
+		"app.host":     "h1",
+		"app.addr":     "${app.host}:80",
+		"app.loop":     "${app.loop2}",
+		"app.loop2":    "${app.loop}",
+	}})
+	dump := c.Dump()
+
+	lines := map[string]string{}
+	for _, l := range strings.Split(strings.TrimSpace(dump), "\n") {
+		kv := strings.SplitN(strings.SplitN(l, "  # ", 2)[0], " = ", 2)
+		lines[kv[0]] = kv[1]
+	}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"db.password", redacted},
+		{"db.url", redacted},
+		{"db.cipher", redacted},
+		{"db.dsn", redacted},
+		{"app.pw.alias", redacted},
+		{"app.indirect", redacted},
+		{"app.link", redacted},
+		{"app.default", redacted},
+		{"app.escaped", "${db.password}"},
+		{"app.host", "h1"},
+		{"app.addr", "h1:80"},
+	}
+	for _, tt := range tests {
+		if got := lines[tt.key]; got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+	if strings.Contains(dump, "hunter2") {
+		t.Fatalf("Dump包含明文密码:\n%s", dump)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	c := NewConfig()
+	c.AddLast(&MapSource{name: "high", items: map[string]string{"a": "1"}})
+	c.AddLast(&MapSource{name: "low", items: map[string]string{"a": "2", "b": "3"}})
+
+	e, err := c.Explain("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Value != "1" || e.Source != "high" || len(e.Shadowed) != 1 || e.Shadowed[0] != (SourceValue{Source: "low", Value: "2"}) {
+		t.Fatalf("Explain(a) = %+v", e)
+	}
+	if _, err := c.Explain("missing"); !NotFound(err) {
+		t.Fatalf("Explain(missing) error = %v, want NotFoundErr", err)
+	}
+}
@@ -0,0 +1,376 @@
+package conf
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrHelp 命令行参数中有-h或--help, 并且没有声明同名的参数时Parse返回的错误
+var ErrHelp = errors.New("conf: 请求帮助信息")
+
+// FlagType 命令行参数值的类型, 解析时检查值是否合法
+type FlagType int
+
+const (
+	FlagString FlagType = iota
+	FlagBool
+	FlagInt
+	FlagFloat
+	FlagDuration
+	// FlagStrings 可以重复出现的参数, 按出现的顺序保存为key[0], key[1]...
+	FlagStrings
+)
+
+func (t FlagType) String() string {
+	switch t {
+	case FlagBool:
+		return "bool"
+	case FlagInt:
+		return "int"
+	case FlagFloat:
+		return "float"
+	case FlagDuration:
+		return "duration"
+	case FlagStrings:
+		return "strings"
+	default:
+		return "string"
+	}
+}
+
+// Flag 声明的命令行参数
+type Flag struct {
+	// Name 长参数名, 同时也是配置项的key, 例如server.port对应--server.port
+	Name string
+	// Short 单个字符的短参数名, 可以为空
+	Short string
+	Type  FlagType
+	// Default 默认值, 通过FlagSet.Defaults作为配置源, FlagStrings类型用,分隔
+	Default string
+	Usage   string
+}
+
+// FlagSet 声明式的命令行参数, 本身是只包含命令行中显式设置的参数的配置源,
+// 默认值通过Defaults单独作为优先级最低的配置源添加:
+//
+//	fs := conf.NewFlagSet("app")
+//	fs.Int("server.port", "p", "8080", "监听端口")
+//	if err := fs.Parse(os.Args[1:]); err != nil { ... }
+//	c.AddFirst(fs)
+//	...
+//	c.AddLast(fs.Defaults())
+//
+// 支持--name=value, --name value, -p value, -p=value, -pvalue, 短参数组合(-abc),
+// bool参数的--no-name, 以及--之后全部作为位置参数
+type FlagSet struct {
+	*MapSource
+	name    string
+	flags   []*Flag
+	byName  map[string]*Flag
+	byShort map[string]*Flag
+	args    []string
+
+	// AllowUnknown 为true时没有声明的参数按CMDLineSource的规则保存, 否则Parse返回错误
+	AllowUnknown bool
+}
+
+// NewFlagSet 创建命令行参数集合, name用于帮助信息
+func NewFlagSet(name string) *FlagSet {
+	return &FlagSet{
+		MapSource: &MapSource{name: "cmd", items: map[string]string{}},
+		name:      name,
+		byName:    map[string]*Flag{},
+		byShort:   map[string]*Flag{},
+	}
+}
+
+// Var 声明参数, 名称重复或短参数名不是单个字符时panic
+func (f *FlagSet) Var(flag Flag) {
+	if flag.Name == "" || strings.HasPrefix(flag.Name, "-") {
+		panic(fmt.Sprintf("命令行参数名错误: %q", flag.Name))
+	}
+	if _, ok := f.byName[flag.Name]; ok {
+		panic(fmt.Sprintf("命令行参数重复声明: %v", flag.Name))
+	}
+	if flag.Short != "" {
+		if len(flag.Short) != 1 || flag.Short == "-" {
+			panic(fmt.Sprintf("短参数名必须是单个字符: %q", flag.Short))
+		}
+		if _, ok := f.byShort[flag.Short]; ok {
+			panic(fmt.Sprintf("短参数重复声明: %v", flag.Short))
+		}
+	}
+
+	fl := flag
+	f.flags = append(f.flags, &fl)
+	f.byName[fl.Name] = &fl
+	if fl.Short != "" {
+		f.byShort[fl.Short] = &fl
+	}
+}
+
+// String 声明字符串参数
+func (f *FlagSet) String(name, short, def, usage string) {
+	f.Var(Flag{Name: name, Short: short, Type: FlagString, Default: def, Usage: usage})
+}
+
+// Bool 声明bool参数, 出现时为true, --no-name为false
+func (f *FlagSet) Bool(name, short, def, usage string) {
+	f.Var(Flag{Name: name, Short: short, Type: FlagBool, Default: def, Usage: usage})
+}
+
+// Int 声明整数参数
+func (f *FlagSet) Int(name, short, def, usage string) {
+	f.Var(Flag{Name: name, Short: short, Type: FlagInt, Default: def, Usage: usage})
+}
+
+// Float 声明浮点数参数
+func (f *FlagSet) Float(name, short, def, usage string) {
+	f.Var(Flag{Name: name, Short: short, Type: FlagFloat, Default: def, Usage: usage})
+}
+
+// Duration 声明时间间隔参数, 格式同time.ParseDuration
+func (f *FlagSet) Duration(name, short, def, usage string) {
+	f.Var(Flag{Name: name, Short: short, Type: FlagDuration, Default: def, Usage: usage})
+}
+
+// Strings 声明可以重复出现的字符串参数
+func (f *FlagSet) Strings(name, short, def, usage string) {
+	f.Var(Flag{Name: name, Short: short, Type: FlagStrings, Default: def, Usage: usage})
+}
+
+// Parse 解析命令行参数, args不包含程序名, 例如os.Args[1:]
+// 可以重复调用, 每次调用替换之前解析的结果; 需要在添加到Config之前调用
+func (f *FlagSet) Parse(args []string) error {
+	items := map[string]string{}
+	var positional, unknown []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			positional = append(positional, args[i+1:]...)
+			i = len(args)
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			fl, negate := f.lookupLong(name)
+			if fl == nil {
+				if name == "help" {
+					return ErrHelp
+				}
+				if !f.AllowUnknown {
+					return errors.Errorf("未知的命令行参数: %v", arg)
+				}
+				unknown = append(unknown, arg)
+				if !hasValue && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+					i++
+					unknown = append(unknown, args[i])
+				}
+				continue
+			}
+			if negate {
+				if hasValue {
+					return errors.Errorf("命令行参数%v不能有值", arg)
+				}
+				value, hasValue = "false", true
+			}
+			if !hasValue {
+				if fl.Type == FlagBool {
+					value = "true"
+				} else if i+1 < len(args) {
+					i++
+					value = args[i]
+				} else {
+					return errors.Errorf("命令行参数%v缺少值", arg)
+				}
+			}
+			if err := f.set(items, fl, value); err != nil {
+				return err
+			}
+		case len(arg) > 1 && arg[0] == '-':
+			consumed, err := f.parseShorts(items, &unknown, args, i)
+			if err != nil {
+				return err
+			}
+			i += consumed
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	for k, v := range parseArgs(unknown) {
+		if _, ok := items[k]; !ok {
+			items[k] = v
+		}
+	}
+	f.args = positional
+	f.setItems(items)
+	return nil
+}
+
+// parseShorts 解析args[i]中的短参数, 返回额外使用的args的个数
+func (f *FlagSet) parseShorts(items map[string]string, unknown *[]string, args []string, i int) (int, error) {
+	arg := args[i]
+	shorts := arg[1:]
+	for j := 0; j < len(shorts); j++ {
+		c := shorts[j : j+1]
+		fl := f.byShort[c]
+		if fl == nil {
+			if c == "h" {
+				return 0, ErrHelp
+			}
+			if !f.AllowUnknown {
+				return 0, errors.Errorf("未知的命令行参数: -%v (%v)", c, arg)
+			}
+			*unknown = append(*unknown, "-"+c)
+			continue
+		}
+
+		rest := shorts[j+1:]
+		if fl.Type == FlagBool {
+			if strings.HasPrefix(rest, "=") {
+				return 0, f.set(items, fl, rest[1:])
+			}
+			if err := f.set(items, fl, "true"); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		// 非bool参数使用剩余的部分或下一个参数作为值
+		if rest != "" {
+			return 0, f.set(items, fl, strings.TrimPrefix(rest, "="))
+		}
+		if i+1 < len(args) {
+			return 1, f.set(items, fl, args[i+1])
+		}
+		return 0, errors.Errorf("命令行参数-%v缺少值", c)
+	}
+	return 0, nil
+}
+
+// lookupLong 查找长参数, 第二个返回值表示是bool参数的--no-name形式
+func (f *FlagSet) lookupLong(name string) (*Flag, bool) {
+	if fl, ok := f.byName[name]; ok {
+		return fl, false
+	}
+	if strings.HasPrefix(name, "no-") {
+		if fl, ok := f.byName[name[3:]]; ok && fl.Type == FlagBool {
+			return fl, true
+		}
+	}
+	return nil, false
+}
+
+// set 检查参数值并保存到items
+func (f *FlagSet) set(items map[string]string, fl *Flag, value string) error {
+	var err error
+	switch fl.Type {
+	case FlagBool:
+		_, err = strconv.ParseBool(value)
+	case FlagInt:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case FlagFloat:
+		_, err = strconv.ParseFloat(value, 64)
+	case FlagDuration:
+		_, err = time.ParseDuration(value)
+	case FlagStrings:
+		n := 0
+		for {
+			if _, ok := items[fmt.Sprintf("%s[%d]", fl.Name, n)]; !ok {
+				break
+			}
+			n++
+		}
+		items[fmt.Sprintf("%s[%d]", fl.Name, n)] = value
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "命令行参数--%v的值%q不是%v类型", fl.Name, value, fl.Type)
+	}
+	items[fl.Name] = value
+	return nil
+}
+
+// Args 返回解析后的位置参数
+func (f *FlagSet) Args() []string {
+	return f.args
+}
+
+// Defaults 返回声明的参数的默认值组成的配置源, 应作为优先级最低的配置源添加
+// 只包含Parse中没有设置的参数, 所以需要在Parse之后调用; 否则FlagStrings的默认值会
+// 按下标与命令行中的值合并, 例如默认值a,b,c和--tags x得到[x b c]
+func (f *FlagSet) Defaults() Source {
+	items := map[string]string{}
+	for _, fl := range f.flags {
+		if fl.Default == "" || f.isSet(fl) {
+			continue
+		}
+		if fl.Type == FlagStrings {
+			for i, s := range strings.Split(fl.Default, ",") {
+				items[fmt.Sprintf("%s[%d]", fl.Name, i)] = strings.TrimSpace(s)
+			}
+			continue
+		}
+		items[fl.Name] = fl.Default
+	}
+	return &MapSource{name: "cmd-defaults", items: items}
+}
+
+// isSet 参数是否在Parse中设置过
+func (f *FlagSet) isSet(fl *Flag) bool {
+	key := fl.Name
+	if fl.Type == FlagStrings {
+		key = fl.Name + "[0]"
+	}
+	_, ok := f.Lookup(key)
+	return ok
+}
+
+// Usage 返回帮助信息, 参数按名称排序
+func (f *FlagSet) Usage() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "用法: %s [选项] [参数...]\n", f.name)
+	if len(f.flags) == 0 {
+		return buf.String()
+	}
+	buf.WriteString("\n选项:\n")
+
+	flags := make([]*Flag, len(f.flags))
+	copy(flags, f.flags)
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Name < flags[j].Name
+	})
+
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	for _, fl := range flags {
+		name := "    "
+		if fl.Short != "" {
+			name = "-" + fl.Short + ", "
+		}
+		name += "--" + fl.Name
+		if fl.Type != FlagBool {
+			name += " " + fl.Type.String()
+		}
+		usage := fl.Usage
+		if fl.Default != "" {
+			usage += fmt.Sprintf(" (默认: %v)", fl.Default)
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", name, usage)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// PrintUsage 将帮助信息输出到标准错误
+func (f *FlagSet) PrintUsage() {
+	fmt.Fprint(os.Stderr, f.Usage())
+}
@@ -0,0 +1,221 @@
+package conf
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestFlagSet() *FlagSet {
+	f := NewFlagSet("app")
+	f.Int("server.port", "p", "8080", "监听端口")
+	f.String("name", "n", "", "名称")
+	f.Bool("verbose", "v", "", "详细输出")
+	f.Bool("quiet", "q", "", "安静模式")
+	f.Float("ratio", "", "", "比例")
+	f.Duration("timeout", "t", "1s", "超时")
+	f.Strings("tags", "", "a,b,c", "标签")
+	return f
+}
+
+func TestFlagSetParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		items map[string]string
+		pos   []string
+	}{
+		{name: "空", args: nil, items: map[string]string{}},
+		{name: "长参数=", args: []string{"--server.port=9090", "--name=x=y"},
+			items: map[string]string{"server.port": "9090", "name": "x=y"}},
+		{name: "长参数空格", args: []string{"--server.port", "9090", "--name", "-x"},
+			items: map[string]string{"server.port": "9090", "name": "-x"}},
+		{name: "短参数", args: []string{"-p", "1", "-n=a", "-t2s"},
+			items: map[string]string{"server.port": "1", "name": "a", "timeout": "2s"}},
+		{name: "短参数组合", args: []string{"-vqp", "80"},
+			items: map[string]string{"verbose": "true", "quiet": "true", "server.port": "80"}},
+		{name: "短参数组合带值", args: []string{"-vnabc"},
+			items: map[string]string{"verbose": "true", "name": "abc"}},
+		{name: "bool", args: []string{"--verbose", "--quiet=false", "-v=false"},
+			items: map[string]string{"verbose": "false", "quiet": "false"}},
+		{name: "no-前缀", args: []string{"--verbose", "--no-verbose"},
+			items: map[string]string{"verbose": "false"}},
+		{name: "bool不使用下一个参数", args: []string{"--verbose", "file"},
+			items: map[string]string{"verbose": "true"}, pos: []string{"file"}},
+		{name: "重复参数", args: []string{"--tags", "x", "--tags=y", "-p", "1", "-p", "2"},
+			items: map[string]string{"tags[0]": "x", "tags[1]": "y", "server.port": "2"}},
+		{name: "位置参数", args: []string{"a", "--name", "n", "-", "b"},
+			items: map[string]string{"name": "n"}, pos: []string{"a", "-", "b"}},
+		{name: "--之后", args: []string{"-v", "--", "--name", "-p"},
+			items: map[string]string{"verbose": "true"}, pos: []string{"--name", "-p"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFlagSet()
+			if err := f.Parse(tt.args); err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.args, err)
+			}
+			if got := mapOf(f.MapSource); !reflect.DeepEqual(got, tt.items) {
+				t.Errorf("Parse(%q) = %v, want %v", tt.args, got, tt.items)
+			}
+			if !reflect.DeepEqual(f.Args(), tt.pos) {
+				t.Errorf("Parse(%q).Args() = %q, want %q", tt.args, f.Args(), tt.pos)
+			}
+		})
+	}
+}
+
+func TestFlagSetParseError(t *testing.T) {
+	tests := []struct {
+		args    []string
+		wantErr string
+	}{
+		{[]string{"--unknown"}, "未知的命令行参数"},
+		{[]string{"-x"}, "未知的命令行参数"},
+		{[]string{"-vx"}, "未知的命令行参数"},
+		{[]string{"--server.port=abc"}, "不是int类型"},
+		{[]string{"-p", "1.5"}, "不是int类型"},
+		{[]string{"--ratio=x"}, "不是float类型"},
+		{[]string{"--timeout=10"}, "不是duration类型"},
+		{[]string{"--verbose=yes"}, "不是bool类型"},
+		{[]string{"--no-verbose=true"}, "不能有值"},
+		{[]string{"--no-name"}, "未知的命令行参数"},
+		{[]string{"--name"}, "缺少值"},
+		{[]string{"-n"}, "缺少值"},
+	}
+	for _, tt := range tests {
+		err := newTestFlagSet().Parse(tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Parse(%q) = %v, want error containing %q", tt.args, err, tt.wantErr)
+		}
+	}
+
+	for _, args := range [][]string{{"-h"}, {"--help"}, {"-vh"}} {
+		if err := newTestFlagSet().Parse(args); !errors.Is(err, ErrHelp) {
+			t.Errorf("Parse(%q) = %v, want ErrHelp", args, err)
+		}
+	}
+	f := newTestFlagSet()
+	f.Bool("help", "h", "", "帮助")
+	if err := f.Parse([]string{"-h"}); err != nil || f.Get("help") != "true" {
+		t.Errorf("声明了help参数时Parse(-h) = %v, help = %q", err, f.Get("help"))
+	}
+}
+
+func TestFlagSetAllowUnknown(t *testing.T) {
+	f := newTestFlagSet()
+	f.AllowUnknown = true
+	err := f.Parse([]string{"--db.host", "h", "--debug", "-x", "--name=n", "--db.port=1", "file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"db.host": "h", "debug": "true", "x": "true", "name": "n", "db.port": "1"}
+	if got := mapOf(f.MapSource); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(f.Args(), []string{"file"}) {
+		t.Errorf("Args() = %q, want [file]", f.Args())
+	}
+}
+
+func TestFlagSetParseReplaces(t *testing.T) {
+	f := newTestFlagSet()
+	if err := f.Parse([]string{"--name", "a", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Parse([]string{"-p", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.Lookup("name"); ok || len(f.Args()) != 0 {
+		t.Errorf("重复Parse应替换之前的结果: %v %q", mapOf(f.MapSource), f.Args())
+	}
+}
+
+func TestFlagSetDefaults(t *testing.T) {
+	tests := []struct {
+		args []string
+		want map[string]string
+	}{
+		{nil, map[string]string{"server.port": "8080", "timeout": "1s", "tags[0]": "a", "tags[1]": "b", "tags[2]": "c"}},
+		{[]string{"--tags", "x"}, map[string]string{"server.port": "8080", "timeout": "1s"}},
+		{[]string{"-p", "1", "-t", "2s"}, map[string]string{"tags[0]": "a", "tags[1]": "b", "tags[2]": "c"}},
+	}
+	for _, tt := range tests {
+		f := newTestFlagSet()
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		if got := mapOf(f.Defaults().(*MapSource)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q).Defaults() = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+
+	// 命令行中的列表不与默认值按下标合并
+	f := newTestFlagSet()
+	if err := f.Parse([]string{"--tags", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	c := NewConfig()
+	c.AddFirst(f)
+	c.AddLast(f.Defaults())
+	var tags []string
+	if err := c.Get("tags", &tags); err != nil || !reflect.DeepEqual(tags, []string{"x"}) {
+		t.Errorf("tags = %q, %v, want [x]", tags, err)
+	}
+	if port := c.MustGetInt("server.port"); port != 8080 {
+		t.Errorf("server.port = %v, want 8080", port)
+	}
+}
+
+func TestFlagSetUsage(t *testing.T) {
+	usage := newTestFlagSet().Usage()
+	for _, s := range []string{
+		"用法: app [选项] [参数...]",
+		"-p, --server.port int",
+		"监听端口 (默认: 8080)",
+		"-v, --verbose ",
+		"    --ratio float",
+		"    --tags strings",
+	} {
+		if !strings.Contains(usage, s) {
+			t.Errorf("Usage()不包含%q:\n%s", s, usage)
+		}
+	}
+	if strings.Contains(usage, "--verbose bool") {
+		t.Errorf("bool参数不应显示类型:\n%s", usage)
+	}
+	if i, j := strings.Index(usage, "--name"), strings.Index(usage, "--tags"); i > j {
+		t.Errorf("参数应按名称排序:\n%s", usage)
+	}
+}
+
+func TestFlagSetVarPanics(t *testing.T) {
+	tests := []Flag{
+		{Name: ""},
+		{Name: "-x"},
+		{Name: "name"},
+		{Name: "other", Short: "p"},
+		{Name: "other", Short: "ab"},
+		{Name: "other", Short: "-"},
+	}
+	for _, fl := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Var(%+v)应panic", fl)
+				}
+			}()
+			newTestFlagSet().Var(fl)
+		}()
+	}
+}
+
+// mapOf 返回MapSource中配置项的副本
+func mapOf(s *MapSource) map[string]string {
+	items := map[string]string{}
+	for _, k := range s.Keys() {
+		items[k] = s.Get(k)
+	}
+	return items
+}
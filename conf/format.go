@@ -0,0 +1,218 @@
+package conf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// 配置内容的格式
+const (
+	FormatYAML       = "yaml"
+	FormatJSON       = "json"
+	FormatTOML       = "toml"
+	FormatProperties = "properties"
+)
+
+// formatOf 根据文件名或dataId的后缀判断配置格式, 无法判断时为YAML
+func formatOf(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	case ".properties":
+		return FormatProperties
+	default:
+		return FormatYAML
+	}
+}
+
+// parseContent 按格式解析配置内容, 展开成key.sub[0]形式的配置项
+func parseContent(format string, data []byte) (map[string]string, error) {
+	switch format {
+	case FormatJSON:
+		return parseJSON(data)
+	case FormatTOML:
+		return parseTOML(data)
+	case FormatProperties:
+		return parseProperties(data)
+	default:
+		return parseYAML(data)
+	}
+}
+
+// 创建JSON的配置源
+func NewJSONSource(name string, data []byte) (Source, error) {
+	items, err := parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return &MapSource{name: name, items: items}, nil
+}
+
+// 创建TOML的配置源
+func NewTOMLSource(name string, data []byte) (Source, error) {
+	items, err := parseTOML(data)
+	if err != nil {
+		return nil, err
+	}
+	return &MapSource{name: name, items: items}, nil
+}
+
+// 创建properties的配置源, properties的key本身就是a.b[0]形式, 不做转换
+func NewPropertiesSource(name string, data []byte) (Source, error) {
+	items, err := parseProperties(data)
+	if err != nil {
+		return nil, err
+	}
+	return &MapSource{name: name, items: items}, nil
+}
+
+func parseJSON(data []byte) (map[string]string, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	// 保留数字的原始文本, 避免大数被转换成1e+06这样的格式
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	items := map[string]string{}
+	addEntry(items, "", v)
+	return items, nil
+}
+
+func parseTOML(data []byte) (map[string]string, error) {
+	var v map[string]interface{}
+	if err := toml.Unmarshal(data, &v); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	items := map[string]string{}
+	addEntry(items, "", v)
+	return items, nil
+}
+
+// parseProperties 解析java properties格式, 支持#和!注释, =, :或空白分隔, \续行和\uXXXX转义
+func parseProperties(data []byte) (map[string]string, error) {
+	items := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var logical string
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if logical == "" && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+
+		// 奇数个\结尾表示续行
+		n := 0
+		for n < len(line) && line[len(line)-1-n] == '\\' {
+			n++
+		}
+		if n%2 == 1 {
+			logical += line[:len(line)-1]
+			continue
+		}
+		logical += line
+
+		key, value, err := splitProperty(logical)
+		if err != nil {
+			return nil, err
+		}
+		items[key] = value
+		logical = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if logical != "" {
+		key, value, err := splitProperty(logical)
+		if err != nil {
+			return nil, err
+		}
+		items[key] = value
+	}
+
+	return items, nil
+}
+
+// splitProperty 分割一行properties的key和value
+func splitProperty(line string) (string, string, error) {
+	i := 0
+	for ; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if line[i] == '=' || line[i] == ':' || line[i] == ' ' || line[i] == '\t' || line[i] == '\f' {
+			break
+		}
+	}
+	if i > len(line) {
+		i = len(line)
+	}
+	key := line[:i]
+
+	rest := strings.TrimLeft(line[i:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+
+	k, err := unescapeProperty(key)
+	if err != nil {
+		return "", "", err
+	}
+	v, err := unescapeProperty(rest)
+	if err != nil {
+		return "", "", err
+	}
+	return k, v, nil
+}
+
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", errors.Errorf("properties转义错误: %s", s)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 32)
+			if err != nil {
+				return "", errors.Wrapf(err, "properties转义错误: %s", s)
+			}
+			var buf [utf8.UTFMax]byte
+			b.Write(buf[:utf8.EncodeRune(buf[:], rune(r))])
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,141 @@
+package conf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProperties(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "分隔符", in: "a=1\nb: 2\nc 3\nd\t=\t4\ne =5", want: map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5"}},
+		{name: "注释和空行", in: "# c\n! c\n\n  \na=1\n  # c", want: map[string]string{"a": "1"}},
+		{name: "没有值", in: "a\nb=", want: map[string]string{"a": "", "b": ""}},
+		{name: "值中的分隔符", in: "url=http://h:80/p?a=b", want: map[string]string{"url": "http://h:80/p?a=b"}},
+		{name: "续行", in: "a=1,\\\n    2,\\\n\t3\nb=x", want: map[string]string{"a": "1,2,3", "b": "x"}},
+		{name: "偶数个反斜杠不续行", in: "a=x\\\\\nb=y", want: map[string]string{"a": "x\\", "b": "y"}},
+		{name: "最后一行续行", in: "a=1\\", want: map[string]string{"a": "1"}},
+		{name: "key中的转义", in: "a\\=b\\:c\\ d=1", want: map[string]string{"a=b:c d": "1"}},
+		{name: "转义字符", in: "a=\\t\\n\\r\\f\\q", want: map[string]string{"a": "\t\n\r\fq"}},
+		{name: "unicode转义", in: "a=\\u4e2d\\u6587", want: map[string]string{"a": "中文"}},
+		{name: "列表key", in: "servers[0]=a\nservers[1]=b", want: map[string]string{"servers[0]": "a", "servers[1]": "b"}},
+		{name: "重复key后面的生效", in: "a=1\na=2", want: map[string]string{"a": "2"}},
+		{name: "unicode转义不完整", in: "a=\\u4e", wantErr: true},
+		{name: "unicode转义错误", in: "a=\\uzzzz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProperties([]byte(tt.in))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseProperties(%q) = %v, want error", tt.in, got)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseProperties(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseContent(t *testing.T) {
+	want := map[string]string{
+		"db.host":        "h",
+		"db.port":        "3306",
+		"db.big":         "12345678901234567890",
+		"servers[0]":     "a",
+		"servers[1]":     "b",
+		"users[0].name":  "u",
+		"flags.enabled":  "true",
+		"ratio":          "0.5",
+		"nested.a.b[0]":  "1",
+		"nested.a.empty": "",
+	}
+	tests := []struct {
+		format string
+		in     string
+	}{
+		{FormatYAML, `
+db: {host: h, port: 3306, big: 12345678901234567890}
+servers: [a, b]
+users: [{name: u}]
+flags: {enabled: true}
+ratio: 0.5
+nested: {a: {b: [1], empty: null}}
+`},
+		{FormatJSON, `{"db": {"host": "h", "port": 3306, "big": 12345678901234567890},
+"servers": ["a", "b"], "users": [{"name": "u"}], "flags": {"enabled": true}, "ratio": 0.5,
+"nested": {"a": {"b": [1], "empty": null}}}`},
+		{FormatProperties, `
+db.host=h
+db.port=3306
+db.big=12345678901234567890
+servers[0]=a
+servers[1]=b
+users[0].name=u
+flags.enabled=true
+ratio=0.5
+nested.a.b[0]=1
+nested.a.empty=
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := parseContent(tt.format, []byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("parseContent(%v) = %v, want %v", tt.format, got, want)
+			}
+		})
+	}
+
+	toml := `
+ratio = 0.5
+servers = ["a", "b"]
+[db]
+host = "h"
+port = 3306
+[[users]]
+name = "u"
+`
+	got, err := parseContent(FormatTOML, []byte(toml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTOML := map[string]string{"ratio": "0.5", "servers[0]": "a", "servers[1]": "b", "db.host": "h", "db.port": "3306", "users[0].name": "u"}
+	if !reflect.DeepEqual(got, wantTOML) {
+		t.Fatalf("parseContent(toml) = %v, want %v", got, wantTOML)
+	}
+
+	for _, format := range []string{FormatYAML, FormatJSON, FormatTOML} {
+		if _, err := parseContent(format, []byte("{{{")); err == nil {
+			t.Errorf("parseContent(%v)解析错误的内容应返回错误", format)
+		}
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]string{
+		"a.json":             FormatJSON,
+		"A.JSON":             FormatJSON,
+		"a.toml":             FormatTOML,
+		"a.properties":       FormatProperties,
+		"a.yaml":             FormatYAML,
+		"a.yml":              FormatYAML,
+		"application":        FormatYAML,
+		"dir.json/a.txt":     FormatYAML,
+		"nacos-data.id.json": FormatJSON,
+	}
+	for name, want := range tests {
+		if got := formatOf(name); got != want {
+			t.Errorf("formatOf(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package conf
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FSSource 创建fs.FS中的配置源, 例如通过//go:embed嵌入程序的默认配置, name使用/分隔
+// name是文件时与FileSource相同, 是目录时与DirSource相同, include的路径相对fs.FS中的文件解析
+// 返回的配置源实现了WatchableSource, 修改时间变化后会重新加载, embed.FS的文件不会变化
+func FSSource(fsys fs.FS, name string) (Source, error) {
+	return FSSourceWithProfiles(fsys, name, nil)
+}
+
+// FSSourceWithProfiles 创建fs.FS中的配置源, YAML文件中带on-profile的文档按profiles激活
+func FSSourceWithProfiles(fsys fs.FS, name string, profiles []string) (Source, error) {
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "创建fs配置错误")
+	}
+	if info.IsDir() {
+		return newDirSource(fsys, "fs-dir:"+name, name, profiles)
+	}
+	return newFileSource(fsys, "fs:"+name, name, profiles)
+}
+
+// FSKeyPerFileSource 创建fs.FS中每个文件一个配置项的目录配置源, 见KeyPerFileSource
+func FSKeyPerFileSource(fsys fs.FS, dir string) (Source, error) {
+	return newKeyPerFileSource(fsys, "fs-keyPerFile:"+dir, dir)
+}
+
+// AddFSSource 添加fs.FS中的配置, 最低优先级, 见FSSource
+// name是文件时与AddFileSource一样同时添加激活的profile对应的文件, 是目录时与AddDirSource相同
+//
+//	//go:embed config
+//	var defaults embed.FS
+//
+//	c.AddFSSource(defaults, "config/application.yaml")
+//	c.AddFirst(fileSource) // 磁盘上的配置覆盖嵌入的默认配置
+func (c *Config) AddFSSource(fsys fs.FS, name string) error {
+	info, err := fs.Stat(fsys, name)
+	if err == nil && info.IsDir() {
+		source, err := FSSourceWithProfiles(fsys, name, c.Profiles())
+		if err != nil {
+			return err
+		}
+		c.AddLast(source)
+		return nil
+	}
+	return c.addFileSource(fsys, name)
+}
+
+// addFileSource 添加文件配置, 有激活的profile时同时添加profile对应的文件
+// 之前没有激活的profile时, 使用file中的profile配置项; 已经有激活的profile(例如来自环境变量)时,
+// file中的profile只是默认值, 不会生效, 并固定激活的profile, 避免Profiles与加载的文件不一致
+func (c *Config) addFileSource(fsys fs.FS, file string) error {
+	profiles := c.Profiles()
+	source, err := fsFileSource(fsys, file, profiles)
+	if err != nil {
+		return err
+	}
+
+	// 在快照上添加file的不可变视图, 计算添加后的profile
+	snap := c.Snapshot()
+	if f, ok := source.(freezer); ok {
+		snap.AddLast(f.freeze())
+	} else {
+		snap.AddLast(source)
+	}
+	if p := snap.Profiles(); !equalStrings(p, profiles) {
+		if len(profiles) > 0 {
+			c.SetProfiles(profiles...)
+		} else {
+			// 按file中的profile重新加载file
+			profiles = p
+			if source, err = fsFileSource(fsys, file, profiles); err != nil {
+				return err
+			}
+		}
+	}
+
+	var sources []Source
+	for i := len(profiles) - 1; i >= 0; i-- {
+		pf := profileFile(file, profiles[i])
+		if _, err := fs.Stat(fsys, pf); os.IsNotExist(err) {
+			continue
+		}
+		source, err := fsFileSource(fsys, pf, profiles)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, source)
+	}
+
+	for _, s := range sources {
+		c.AddLast(s)
+	}
+	c.AddLast(source)
+	return nil
+}
+
+// fsFileSource 创建文件配置源, 真实路径的名称与FileSource相同
+func fsFileSource(fsys fs.FS, file string, profiles []string) (Source, error) {
+	if isOSFS(fsys) {
+		return FileSourceWithProfiles(file, profiles)
+	}
+	return newFileSource(fsys, "fs:"+file, file, profiles)
+}
+
+// osFS 使用真实路径的文件系统, 与os.DirFS不同, 路径可以是绝对路径或相对当前目录的路径
+// 文件配置源都通过fs.FS读取, FileSource等使用osFS
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func isOSFS(fsys fs.FS) bool {
+	_, ok := fsys.(osFS)
+	return ok
+}
+
+// joinPath 解析相对dir的路径name, osFS按操作系统的路径处理,
+// 其他fs.FS使用/分隔的路径, /开头的路径相对fs.FS的根目录
+func joinPath(fsys fs.FS, dir, name string) string {
+	if isOSFS(fsys) {
+		if filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+	if strings.HasPrefix(name, "/") {
+		return path.Clean(strings.TrimPrefix(name, "/"))
+	}
+	return path.Join(dir, name)
+}
+
+// dirPath 返回文件所在的目录
+func dirPath(fsys fs.FS, name string) string {
+	if isOSFS(fsys) {
+		return filepath.Dir(name)
+	}
+	return path.Dir(name)
+}
+
+// baseName 返回路径的最后一部分
+func baseName(fsys fs.FS, name string) string {
+	if isOSFS(fsys) {
+		return filepath.Base(name)
+	}
+	return path.Base(name)
+}
+
+// samePath 两个路径是否指向同一个文件
+func samePath(fsys fs.FS, a, b string) bool {
+	if !isOSFS(fsys) {
+		return path.Clean(a) == path.Clean(b)
+	}
+	aa, err1 := filepath.Abs(a)
+	ab, err2 := filepath.Abs(b)
+	if err1 != nil || err2 != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return aa == ab
+}
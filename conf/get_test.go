@@ -0,0 +1,219 @@
+package conf
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newMapConfig 创建只有一个MapSource的配置
+func newMapConfig(items map[string]string) *Config {
+	c := NewConfig()
+	c.AddLast(&MapSource{name: "m", items: items})
+	return c
+}
+
+func TestGetMap(t *testing.T) {
+	type upstream struct {
+		Host  string
+		Ports []int
+	}
+	c := newMapConfig(map[string]string{
+		"labels.env":           "prod",
+		"labels.team":          "infra",
+		"weights.1":            "10",
+		"weights.20":           "5",
+		"ids.7":                "a",
+		"upstreams.a.host":     "h1",
+		"upstreams.a.ports[0]": "80",
+		"upstreams.a.ports[1]": "443",
+		"upstreams.bB.host":    "h2",
+		"groups.admin[0]":      "alice",
+		"groups.admin[1]":      "bob",
+		"groups.dev[0]":        "carol",
+		"nested.x.y":           "1",
+		"nested.x.z":           "2",
+		"nested.w.y":           "3",
+		"bad.abc":              "1",
+		"labelsx.other":        "not a child of labels",
+	})
+
+	tests := []struct {
+		key  string
+		ptr  interface{}
+		want interface{}
+	}{
+		{"labels", new(map[string]string), map[string]string{"env": "prod", "team": "infra"}},
+		{"weights", new(map[int]int), map[int]int{1: 10, 20: 5}},
+		{"ids", new(map[uint8]string), map[uint8]string{7: "a"}},
+		{"upstreams", new(map[string]upstream), map[string]upstream{
+			"a":  {Host: "h1", Ports: []int{80, 443}},
+			"bB": {Host: "h2"},
+		}},
+		{"groups", new(map[string][]string), map[string][]string{"admin": {"alice", "bob"}, "dev": {"carol"}}},
+		{"nested", new(map[string]map[string]int), map[string]map[string]int{"x": {"y": 1, "z": 2}, "w": {"y": 3}}},
+	}
+	for _, tt := range tests {
+		if err := c.Get(tt.key, tt.ptr); err != nil {
+			t.Errorf("Get(%v) error: %v", tt.key, err)
+			continue
+		}
+		if got := reflect.ValueOf(tt.ptr).Elem().Interface(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Get(%v) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+
+	// 已有的map保留原来的元素, 配置项覆盖同名的元素
+	m := map[string]string{"env": "dev", "old": "1"}
+	if err := c.Get("labels", &m); err != nil || !reflect.DeepEqual(m, map[string]string{"env": "prod", "team": "infra", "old": "1"}) {
+		t.Errorf("Get(labels)到已有的map = %v, %v", m, err)
+	}
+
+	var missing map[string]string
+	if err := c.Get("missing", &missing); !NotFound(err) || missing != nil {
+		t.Errorf("Get(missing) = %v, %v, want nil, NotFoundErr", missing, err)
+	}
+	var bad map[int]int
+	if err := c.Get("bad", &bad); err == nil || NotFound(err) {
+		t.Errorf("Get(bad)的key不是数字时应返回转换错误: %v", err)
+	}
+}
+
+func TestSub(t *testing.T) {
+	c := newMapConfig(map[string]string{
+		"redis.host":         "h",
+		"redis.port":         "6379",
+		"redis.nodes[0]":     "n0",
+		"redis.cluster.name": "c",
+		"redisx.host":        "other",
+		"servers[0].name":    "s0",
+		"addr":               "${redis.host}:${redis.port}",
+		"redis.addr":         "${redis.host}:${redis.port}",
+	})
+
+	tests := []struct {
+		sub  *Config
+		keys []string
+	}{
+		{c.Sub("redis"), []string{"addr", "cluster.name", "host", "nodes[0]", "port"}},
+		{c.Sub("redis").Sub("cluster"), []string{"name"}},
+		{c.Sub("redis.cluster"), []string{"name"}},
+		{c.Sub("redis").Sub("nodes"), []string{"[0]"}},
+		{c.Sub("servers"), []string{"[0].name"}},
+		{c.Sub("missing"), nil},
+	}
+	for _, tt := range tests {
+		keys := tt.sub.Keys()
+		if len(keys) == 0 && len(tt.keys) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(keys, tt.keys) {
+			t.Errorf("Sub(%v).Keys() = %q, want %q", tt.sub.prefix, keys, tt.keys)
+		}
+	}
+
+	redis := c.Sub("redis")
+	if v := redis.MustGetString("host"); v != "h" {
+		t.Errorf("Sub(redis).host = %q, want h", v)
+	}
+	if v := redis.MustGetInt("port"); v != 6379 {
+		t.Errorf("Sub(redis).port = %v, want 6379", v)
+	}
+	if v := redis.Sub("cluster").MustGetString("name"); v != "c" {
+		t.Errorf("Sub(redis).Sub(cluster).name = %q, want c", v)
+	}
+	if v := c.Sub("servers").Sub("[0]").MustGetString("name"); v != "s0" {
+		t.Errorf("Sub(servers).Sub([0]).name = %q, want s0", v)
+	}
+	// 占位符中的key是完整的key
+	if v := redis.MustGetString("addr"); v != "h:6379" {
+		t.Errorf("Sub(redis).addr = %q, want h:6379", v)
+	}
+	if _, err := redis.GetString("redisx.host"); !NotFound(err) {
+		t.Errorf("Sub(redis)不应读到redisx.host: %v", err)
+	}
+
+	var r struct {
+		Host  string
+		Nodes []string
+	}
+	if err := c.Sub("redis").Get("", &r); err != nil || r.Host != "h" || len(r.Nodes) != 1 {
+		t.Errorf("Sub(redis).Get(\"\") = %+v, %v", r, err)
+	}
+
+	// 子配置共享原配置的配置源
+	c.AddFirst(&MapSource{name: "override", items: map[string]string{"redis.host": "h2", "redis.db": "1"}})
+	if v := redis.MustGetString("host"); v != "h2" {
+		t.Errorf("添加配置源后Sub(redis).host = %q, want h2", v)
+	}
+	want := []string{"addr", "cluster.name", "db", "host", "nodes[0]", "port"}
+	if keys := redis.Keys(); !reflect.DeepEqual(keys, want) {
+		t.Errorf("添加配置源后Sub(redis).Keys() = %q, want %q", keys, want)
+	}
+}
+
+func TestDefaultTag(t *testing.T) {
+	type pool struct {
+		Size int    `default:"10"`
+		Mode string `default:"lifo"`
+	}
+	type config struct {
+		Host    string   `default:"localhost"`
+		Port    int      `default:"8080"`
+		Debug   bool     `default:"true"`
+		Tags    []string `default:"a, b ,c"`
+		Ports   []int    `default:"80,443"`
+		Pair    [2]int   `default:"1,2"`
+		Empty   string   `default:""`
+		Pool    pool
+		Pools   map[string]pool
+		Timeout time.Duration `default:"1s"`
+	}
+
+	tests := []struct {
+		name  string
+		items map[string]string
+		want  config
+	}{
+		{
+			name:  "全部使用默认值",
+			items: map[string]string{"app.other": "x"},
+			want: config{Host: "localhost", Port: 8080, Debug: true, Tags: []string{"a", "b", "c"},
+				Ports: []int{80, 443}, Pair: [2]int{1, 2}, Pool: pool{Size: 10, Mode: "lifo"}, Timeout: time.Second},
+		},
+		{
+			name: "配置项覆盖默认值",
+			items: map[string]string{
+				"app.host": "h", "app.debug": "false", "app.tags[0]": "x", "app.ports[0]": "1",
+				"app.pool.size": "5", "app.pools.p1.mode": "fifo", "app.empty": "e",
+			},
+			want: config{Host: "h", Port: 8080, Debug: false, Tags: []string{"x"}, Ports: []int{1}, Pair: [2]int{1, 2},
+				Empty: "e", Pool: pool{Size: 5, Mode: "lifo"}, Pools: map[string]pool{"p1": {Size: 10, Mode: "fifo"}},
+				Timeout: time.Second},
+		},
+		{
+			name:  "显式配置为空",
+			items: map[string]string{"app.host": "", "app.port": ""},
+			want: config{Host: "", Port: 8080, Debug: true, Tags: []string{"a", "b", "c"},
+				Ports: []int{80, 443}, Pair: [2]int{1, 2}, Pool: pool{Size: 10, Mode: "lifo"}, Timeout: time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got config
+			if err := newMapConfig(tt.items).Get("app", &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Get(app) = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var bad struct {
+		Port int `default:"abc"`
+	}
+	if err := newMapConfig(map[string]string{"app.x": "1"}).Get("app", &bad); err == nil || NotFound(err) {
+		t.Errorf("default值错误时应返回转换错误: %v", err)
+	}
+}
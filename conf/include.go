@@ -0,0 +1,74 @@
+package conf
+
+import (
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// IncludeKey 配置文件中引入其他配置文件的配置项, 可以是单个文件或文件列表:
+//
+//	include: [common-db.yaml, common-redis.yaml]
+//
+// 只有FileSource, DirSource和FSSource等文件配置源处理include, 路径相对所在的文件;
+// NewYAMLSource和远程配置源不处理, 避免远程的配置内容读取本地文件
+const IncludeKey = "include"
+
+// includeItems 合并items中include的文件, 相对路径基于dir
+// 按include的顺序合并, 后面的文件覆盖前面的, items本身的配置项优先级最高
+// include的文件可以继续include, stack是正在加载的文件, 出现循环时返回错误
+func includeItems(fsys fs.FS, items map[string]string, dir string, profiles []string, stack []string, modTimes map[string]time.Time) (map[string]string, error) {
+	files := takeIncludes(items)
+	if len(files) == 0 {
+		return items, nil
+	}
+
+	merged := map[string]string{}
+	for _, file := range files {
+		file = joinPath(fsys, dir, file)
+		for _, f := range stack {
+			if samePath(fsys, f, file) {
+				return nil, errors.Errorf("配置文件循环include: %v -> %v", strings.Join(stack, " -> "), file)
+			}
+		}
+		inc, err := loadFileIncludes(fsys, file, profiles, stack, modTimes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "include配置文件错误: %v", file)
+		}
+		mergeItems(merged, inc)
+	}
+	mergeItems(merged, items)
+	return merged, nil
+}
+
+// takeIncludes 返回并删除items中的include配置项
+func takeIncludes(items map[string]string) []string {
+	var files []string
+	if v, ok := items[IncludeKey]; ok {
+		delete(items, IncludeKey)
+		if v != "" {
+			files = append(files, v)
+		}
+	}
+
+	var list []string
+	for k := range items {
+		if strings.HasPrefix(k, IncludeKey+"[") && strings.HasSuffix(k, "]") && isDigits(k[len(IncludeKey)+1:len(k)-1]) {
+			list = append(list, k)
+		}
+	}
+	// 按下标排序
+	sort.Slice(list, func(i, j int) bool {
+		return len(list[i]) < len(list[j]) || len(list[i]) == len(list[j]) && list[i] < list[j]
+	})
+	for _, k := range list {
+		if v := items[k]; v != "" {
+			files = append(files, v)
+		}
+		delete(items, k)
+	}
+	return files
+}
@@ -0,0 +1,73 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFileSourceInclude(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "common"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{
+		"app.yaml":                "include: [common/db.yaml, common/redis.properties]\na: app\n",
+		"common/db.yaml":          "include: base.json\na: db\ndb.host: h\n",
+		"common/base.json":        `{"a": "base", "base": "1", "db": {"port": 3306}}`,
+		"common/redis.properties": "redis.host=r\ndb.host=redis\n",
+		"loop1.yaml":              "include: loop2.yaml\n",
+		"loop2.yaml":              "include: [" + filepath.Join(dir, "loop1.yaml") + "]\n",
+	})
+
+	s, err := FileSource(filepath.Join(dir, "app.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a": "app", "base": "1", "db.host": "redis", "db.port": "3306", "redis.host": "r"}
+	if got := mapOf(s.(*pollSource).MapSource); !reflect.DeepEqual(got, want) {
+		t.Errorf("FileSource() = %v, want %v", got, want)
+	}
+
+	if _, err := FileSource(filepath.Join(dir, "loop1.yaml")); err == nil || !strings.Contains(err.Error(), "循环include") {
+		t.Errorf("循环include应返回错误: %v", err)
+	}
+}
+
+func TestFSSourceInclude(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config/app.yaml": {Data: []byte("include: [db.yaml, /shared.yaml]\na: app\n")},
+		"config/db.yaml":  {Data: []byte("db.host: h\n")},
+		"shared.yaml":     {Data: []byte("shared: s\na: shared\n")},
+		"config/bad.yaml": {Data: []byte("include: missing.yaml\n")},
+	}
+	s, err := FSSource(fsys, "config/app.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a": "app", "db.host": "h", "shared": "s"}
+	if got := mapOf(s.(*pollSource).MapSource); !reflect.DeepEqual(got, want) {
+		t.Errorf("FSSource() = %v, want %v", got, want)
+	}
+	if _, err := FSSource(fsys, "config/bad.yaml"); err == nil {
+		t.Error("include不存在的文件应返回错误")
+	}
+}
+
+func TestYAMLSourceIgnoresInclude(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret.yaml")
+	writeFiles(t, dir, map[string]string{"secret.yaml": "password: p\n"})
+
+	s, err := NewYAMLSource("remote", []byte("include: ["+secret+", missing.yaml]\na: 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"include[0]": secret, "include[1]": "missing.yaml", "a": "1"}
+	if got := mapOf(s.(*MapSource)); !reflect.DeepEqual(got, want) {
+		t.Errorf("NewYAMLSource() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+package conf
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// resolve 替换value中的占位符, stack是正在解析的key, 用于检测循环引用
+//
+//	${key}          替换为配置项key的值, 配置中没有时使用同名的环境变量
+//	${key:default}  配置项和环境变量都没有时使用default, default中也可以包含占位符
+//	$${             转义, 输出${
+//
+// 占位符中的key总是完整的key, 不受Sub视图前缀的影响
+func (c *Config) resolve(value string, stack []string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); {
+		if strings.HasPrefix(value[i:], "$${") {
+			b.WriteString("${")
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(value[i:], "${") {
+			b.WriteByte(value[i])
+			i++
+			continue
+		}
+
+		end := placeholderEnd(value, i+2)
+		if end < 0 {
+			// 没有结束的}, 原样输出
+			b.WriteString(value[i:])
+			break
+		}
+
+		v, err := c.placeholder(value[i+2:end], stack)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(v)
+		i = end + 1
+	}
+	return b.String(), nil
+}
+
+// placeholder 解析占位符${}中的内容
+func (c *Config) placeholder(expr string, stack []string) (string, error) {
+	name, def, hasDef := expr, "", false
+	if i := defaultSep(expr); i >= 0 {
+		name, def, hasDef = expr[:i], expr[i+1:], true
+	}
+	name = strings.TrimSpace(name)
+
+	for _, k := range stack {
+		if k == name {
+			return "", errors.Errorf("占位符循环引用: %s -> %s", strings.Join(stack, " -> "), name)
+		}
+	}
+
+	v, err := c.lookup(name)
+	if err == nil {
+		return c.expand(name, v, append(stack, name))
+	}
+	if env, ok := os.LookupEnv(name); ok {
+		return env, nil
+	}
+	if hasDef {
+		return c.resolve(def, stack)
+	}
+	return "", errors.Errorf("占位符${%s}无法解析, key: %s", name, stack[len(stack)-1])
+}
+
+// placeholderEnd 返回从start开始与${匹配的}的位置, 支持嵌套的占位符
+func placeholderEnd(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case s[i] == '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// defaultSep 返回占位符中key与默认值之间:的位置, 忽略嵌套占位符中的:
+func defaultSep(expr string) int {
+	depth := 0
+	for i := 0; i < len(expr); i++ {
+		switch {
+		case strings.HasPrefix(expr[i:], "${"):
+			depth++
+			i++
+		case expr[i] == '}':
+			depth--
+		case expr[i] == ':' && depth == 0:
+			return i
+		}
+	}
+	return -1
+}
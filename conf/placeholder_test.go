@@ -0,0 +1,111 @@
+package conf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolvePlaceholders(t *testing.T) {
+	t.Setenv("PH_TEST_ENV", "fromenv")
+	t.Setenv("PH_TEST_SHADOW", "env")
+
+	c := NewConfig()
+	c.AddLast(&MapSource{name: "m", items: map[string]string{
+		"host":           "h",
+		"port":           "80",
+		"PH_TEST_SHADOW": "config",
+		"addr":           "${host}:${port}",
+		"url":            "http://${addr}/p",
+		"empty":          "",
+		"loop.a":         "${loop.b}",
+		"loop.b":         "${loop.a}",
+		"self":           "x${self}",
+		"bad":            "${nope}",
+		"sub.name":       "${host}",
+	}})
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr string
+	}{
+		{in: "plain", want: "plain"},
+		{in: "${host}", want: "h"},
+		{in: "a${host}b${port}c", want: "ahb80c"},
+		{in: "${url}", want: "http://h:80/p"},
+		{in: "${ host }", want: "h"},
+		{in: "${empty}", want: ""},
+		{in: "${empty:def}", want: ""},
+		{in: "${missing:def}", want: "def"},
+		{in: "${missing:}", want: ""},
+		{in: "${missing:a:b}", want: "a:b"},
+		{in: "${missing:${host}}", want: "h"},
+		{in: "${missing:${missing2:${port}}}", want: "80"},
+		{in: "${PH_TEST_ENV}", want: "fromenv"},
+		{in: "${PH_TEST_SHADOW}", want: "config"},
+		{in: "$${host}", want: "${host}"},
+		{in: "$$${host}", want: "$${host}"},
+		{in: "${host", want: "${host"},
+		{in: "$host}", want: "$host}"},
+		{in: "${missing}", wantErr: "无法解析"},
+		{in: "${loop.a}", wantErr: "循环引用"},
+		{in: "${self}", wantErr: "循环引用"},
+		{in: "${bad}", wantErr: "无法解析"},
+	}
+	for _, tt := range tests {
+		got, err := c.resolve(tt.in, []string{"test"})
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("resolve(%q) = %q, %v, want error containing %q", tt.in, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolve(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+
+	// 占位符中的key总是完整的key, 不受Sub的前缀影响
+	if v := c.Sub("sub").MustGetString("name"); v != "h" {
+		t.Errorf("Sub(sub).name = %q, want h", v)
+	}
+	if _, err := c.GetString("loop.a"); err == nil {
+		t.Error("GetString(loop.a)应返回循环引用错误")
+	}
+}
+
+func TestGetStringDefault(t *testing.T) {
+	c := NewConfig()
+	c.AddLast(&MapSource{name: "m", items: map[string]string{
+		"a":                 "1",
+		"bad":               "${nope}",
+		"nacos.url":         "http://127.0.0.1:1",
+		"nacos.namespaceId": "ns",
+		"nacos.dataId":      "id",
+		"nacos.password":    "ENC(AAAA)",
+		"config.file":       "${nope}",
+	}})
+
+	if v := c.GetStringDefault("a", "d"); v != "1" {
+		t.Errorf("GetStringDefault(a) = %q, want 1", v)
+	}
+	if v := c.GetStringDefault("missing", "d"); v != "d" {
+		t.Errorf("GetStringDefault(missing) = %q, want d", v)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("GetStringDefault(bad)占位符无法解析时应panic")
+			}
+		}()
+		c.GetStringDefault("bad", "d")
+	}()
+
+	// 无法解密的密码返回错误, 不会用空密码登录
+	if err := c.AddNacosSourceFromConfig(); err == nil || !strings.Contains(err.Error(), "nacos.password") {
+		t.Errorf("AddNacosSourceFromConfig() = %v, want nacos.password的错误", err)
+	}
+	if err := c.AddFileSourceFromConfig(); err == nil || !strings.Contains(err.Error(), "无法解析") {
+		t.Errorf("AddFileSourceFromConfig() = %v, want 占位符错误", err)
+	}
+}
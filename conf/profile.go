@@ -0,0 +1,75 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ProfileKey 激活profile的配置项, 可以通过命令行--profile=prod或其他配置源设置
+	ProfileKey = "profile"
+	// ProfileEnv 配置中没有ProfileKey时, 从这个环境变量读取激活的profile
+	ProfileEnv = "CONF_PROFILE"
+
+	// YAML文档中指定文档生效的profile的key
+	onProfileKey = "on-profile"
+)
+
+// SetProfiles 设置激活的profile, 设置后不再从配置和环境变量读取
+func (c *Config) SetProfiles(profiles ...string) {
+	c.base().profiles = profiles
+}
+
+// Profiles 返回激活的profile, 依次从SetProfiles, 配置项profile, 环境变量CONF_PROFILE读取, 多个profile用逗号分隔
+func (c *Config) Profiles() []string {
+	b := c.base()
+	if b.profiles != nil {
+		return b.profiles
+	}
+	s, err := b.GetString(ProfileKey)
+	if err != nil {
+		s = os.Getenv(ProfileEnv)
+	}
+	return splitProfiles(s)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func splitProfiles(s string) []string {
+	var profiles []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			profiles = append(profiles, p)
+		}
+	}
+	return profiles
+}
+
+// profileActive on-profile中的任意一个profile被激活时返回true
+func profileActive(on string, profiles []string) bool {
+	for _, p := range splitProfiles(on) {
+		for _, active := range profiles {
+			if p == active {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// profileFile 返回profile对应的文件名, 例如application.yaml对应application-prod.yaml
+func profileFile(file, profile string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "-" + profile + ext
+}
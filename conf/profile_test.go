@@ -0,0 +1,108 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAddFileSourceProfileFromFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"application.yaml":      "profile: prod\na: base\nb: base\n---\non-profile: prod\nb: doc\n",
+		"application-prod.yaml": "a: prod\n",
+		"application-dev.yaml":  "a: dev\n",
+	})
+	t.Setenv(ProfileEnv, "")
+
+	tests := []struct {
+		name    string
+		before  map[string]string
+		env     string
+		wantA   string
+		wantB   string
+		profile string
+	}{
+		{name: "文件中的profile", wantA: "prod", wantB: "doc", profile: "prod"},
+		{name: "之前的配置源优先", before: map[string]string{"profile": "dev"}, wantA: "dev", wantB: "base", profile: "dev"},
+		{name: "环境变量优先于文件中的profile", env: "dev", wantA: "dev", wantB: "base", profile: "dev"},
+		{name: "之前的配置源优先于环境变量", before: map[string]string{"profile": "prod"}, env: "dev", wantA: "prod", wantB: "doc", profile: "prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ProfileEnv, tt.env)
+			c := NewConfig()
+			if tt.before != nil {
+				c.AddLast(&MapSource{name: "before", items: tt.before})
+			}
+			if err := c.AddFileSource(filepath.Join(dir, "application.yaml")); err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			if p := c.Profiles(); len(p) != 1 || p[0] != tt.profile {
+				t.Fatalf("Profiles() = %v, want [%v]", p, tt.profile)
+			}
+			if a := c.MustGetString("a"); a != tt.wantA {
+				t.Errorf("a = %q, want %q", a, tt.wantA)
+			}
+			if b := c.MustGetString("b"); b != tt.wantB {
+				t.Errorf("b = %q, want %q", b, tt.wantB)
+			}
+		})
+	}
+}
+
+func TestSplitProfiles(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"prod", []string{"prod"}},
+		{" prod , gray ,", []string{"prod", "gray"}},
+	}
+	for _, tt := range tests {
+		if got := splitProfiles(tt.in); !equalStrings(got, tt.want) {
+			t.Errorf("splitProfiles(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseYAMLProfiles(t *testing.T) {
+	in := `
+a: base
+l: [1, 2, 3]
+---
+on-profile: prod
+a: prod
+l: [9]
+---
+on-profile: dev, test
+a: dev
+`
+	tests := []struct {
+		profiles []string
+		want     map[string]string
+	}{
+		{nil, map[string]string{"a": "base", "l[0]": "1", "l[1]": "2", "l[2]": "3"}},
+		{[]string{"prod"}, map[string]string{"a": "prod", "l[0]": "9"}},
+		{[]string{"test"}, map[string]string{"a": "dev", "l[0]": "1", "l[1]": "2", "l[2]": "3"}},
+	}
+	for _, tt := range tests {
+		got, err := parseYAMLProfiles([]byte(in), tt.profiles)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseYAMLProfiles(%v) = %v, %v, want %v", tt.profiles, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package conf
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// snapshot 配置源的不可变快照
+type snapshot struct {
+	// sources 添加的配置源, 用于监听和关闭
+	sources []Source
+	// frozen 配置源当前内容的不可变视图, 读取配置项时使用
+	frozen []Source
+
+	// index 配置项所在的优先级最高的MapSource在frozen中的位置
+	index map[string]int
+	// live 不能建立索引的配置源在frozen中的位置, 按优先级排列
+	live []int
+
+	keysOnce sync.Once
+	// allKeys 所有配置源的配置项合并排序后的列表, 第一次使用时生成
+	allKeys []string
+
+	// cacheable 所有配置源都是不可变视图时, 解析后的值可以缓存到快照更新
+	cacheable bool
+	cache     sync.Map
+}
+
+var emptySnapshot = &snapshot{}
+
+// freezer 可以返回当前内容的不可变视图的配置源, 没有实现的配置源在快照中直接使用
+type freezer interface {
+	freeze() Source
+}
+
+func newSnapshot(sources []Source) *snapshot {
+	frozen := make([]Source, len(sources))
+	for i, s := range sources {
+		if f, ok := s.(freezer); ok {
+			frozen[i] = f.freeze()
+		} else {
+			frozen[i] = s
+		}
+	}
+	return indexSnapshot(sources, frozen)
+}
+
+// indexSnapshot 为frozen中的配置源建立合并的索引
+func indexSnapshot(sources, frozen []Source) *snapshot {
+	s := &snapshot{sources: sources, frozen: frozen, index: map[string]int{}, cacheable: true}
+	for i, src := range frozen {
+		// 只有MapSource的Lookup就是按原样查找map, 其它配置源(如环境变量)需要调用Lookup
+		m, ok := src.(*MapSource)
+		if !ok {
+			s.live = append(s.live, i)
+			if _, ok := sources[i].(freezer); !ok {
+				s.cacheable = false
+			}
+			continue
+		}
+		for k := range m.items {
+			if _, ok := s.index[k]; !ok {
+				s.index[k] = i
+			}
+		}
+	}
+	return s
+}
+
+// lookup 按优先级查找配置项
+func (s *snapshot) lookup(key string) (string, bool) {
+	pos, ok := s.index[key]
+	if !ok {
+		pos = len(s.frozen)
+	}
+	// 优先级比索引中的配置源高的其它配置源
+	for _, i := range s.live {
+		if i >= pos {
+			break
+		}
+		if v, ok := s.frozen[i].Lookup(key); ok {
+			return v, true
+		}
+	}
+	if !ok {
+		return "", false
+	}
+	return s.frozen[pos].(*MapSource).items[key], true
+}
+
+// keys 返回所有配置源的配置项名, 已排序并去重, 调用方不能修改
+func (s *snapshot) keys() []string {
+	s.keysOnce.Do(func() {
+		seen := make(map[string]bool, len(s.index))
+		add := func(k string) {
+			if !seen[k] {
+				seen[k] = true
+				s.allKeys = append(s.allKeys, k)
+			}
+		}
+		// 环境变量的key是小写的, 其它配置源的key都加入后再按其它配置源的写法添加
+		var envs []KeysSource
+		for _, src := range s.frozen {
+			ks, ok := src.(KeysSource)
+			if !ok {
+				continue
+			}
+			if _, ok := src.(*envSource); ok {
+				envs = append(envs, ks)
+				continue
+			}
+			for _, k := range ks.Keys() {
+				add(k)
+			}
+		}
+		if len(envs) > 0 {
+			spellings := keySpellings(s.allKeys)
+			for _, ks := range envs {
+				for _, k := range ks.Keys() {
+					add(respell(k, spellings))
+				}
+			}
+		}
+		sort.Strings(s.allKeys)
+	})
+	return s.allKeys
+}
+
+// keySpellings 返回keys和keys中以.或[结束的前缀的小写形式到原写法的映射
+func keySpellings(keys []string) map[string]string {
+	spellings := map[string]string{}
+	for _, k := range keys {
+		lower := strings.ToLower(k)
+		if len(lower) != len(k) {
+			// 非ASCII字符转换后长度变化, 只能整体对应
+			if _, ok := spellings[lower]; !ok {
+				spellings[lower] = k
+			}
+			continue
+		}
+		for i := 0; i <= len(k); i++ {
+			if i == len(k) || k[i] == '.' || k[i] == '[' {
+				if _, ok := spellings[lower[:i]]; !ok {
+					spellings[lower[:i]] = k[:i]
+				}
+			}
+		}
+	}
+	return spellings
+}
+
+// respell 把小写的key中最长的已有前缀替换成其它配置源中的写法,
+// 例如其它配置源中有upstreams.myService.host时, upstreams.myservice.port对应upstreams.myService.port
+func respell(key string, spellings map[string]string) string {
+	for i := len(key); i > 0; i-- {
+		if i < len(key) && key[i] != '.' && key[i] != '[' {
+			continue
+		}
+		if sp, ok := spellings[key[:i]]; ok {
+			return sp + key[i:]
+		}
+	}
+	return key
+}
+
+// keysWithPrefix 返回排序的keys中以prefix开头的部分
+func keysWithPrefix(keys []string, prefix string) []string {
+	i := sort.SearchStrings(keys, prefix)
+	j := i
+	for j < len(keys) && strings.HasPrefix(keys[j], prefix) {
+		j++
+	}
+	return keys[i:j]
+}
+
+// cachedValue 从快照缓存中读取解析后的配置值, 没有时调用parse并缓存
+// 快照在配置源更新时整体替换, 缓存随之失效; 出错时不缓存
+func cachedValue[T any](c *Config, kind, key string, parse func() (T, error)) (T, error) {
+	s := c.base().load()
+	if !s.cacheable {
+		return parse()
+	}
+	ck := kind + "\x00" + c.fullKey(key)
+	if v, ok := s.cache.Load(ck); ok {
+		return v.(T), nil
+	}
+	v, err := parse()
+	if err == nil {
+		s.cache.Store(ck, v)
+	}
+	return v, err
+}
+
+// load 返回当前快照
+func (c *Config) load() *snapshot {
+	if s, ok := c.state.Load().(*snapshot); ok {
+		return s
+	}
+	return emptySnapshot
+}
+
+// publish 配置源内容更新后重新生成并发布快照
+func (c *Config) publish() {
+	c.writeMu.Lock()
+	c.state.Store(newSnapshot(c.load().sources))
+	c.writeMu.Unlock()
+}
+
+// Snapshot 返回当前配置的只读快照, 快照中的值不会随配置源的更新而变化,
+// 用于一致地读取多个相关的配置项, 不会读到更新了一半的配置
+// 快照与原配置相互独立, 在快照上添加配置源不影响原配置
+func (c *Config) Snapshot() *Config {
+	b := c.base()
+	frozen := b.load().frozen
+
+	snap := &Config{prefix: c.prefix, decryptor: b.decryptor, profiles: b.profiles}
+	snap.state.Store(indexSnapshot(frozen, frozen))
+	return snap
+}
+
+// freeze 返回当前配置项的不可变视图, 配置项的map只会被整体替换, 不会被修改, 可以直接共享
+func (s *MapSource) freeze() Source {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &MapSource{name: s.name, items: s.items}
+}
+
+func (s *envSource) freeze() Source {
+	return &envSource{MapSource: s.MapSource.freeze().(*MapSource)}
+}
@@ -0,0 +1,262 @@
+package conf
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// legacyMap 旧接口的配置源, 每次Get都读取当前的map, 用于验证不可冻结的配置源不被缓存
+type legacyMap map[string]string
+
+func (m legacyMap) Name() string          { return "legacy" }
+func (m legacyMap) Get(key string) string { return m[key] }
+
+func TestSnapshotLookupPriority(t *testing.T) {
+	t.Setenv("SNAP_ENVONLY", "env")
+	t.Setenv("SNAP_SHADOW", "env")
+	t.Setenv("SNAP_TOP", "env")
+
+	c := NewConfig()
+	c.AddLast(&MapSource{name: "high", items: map[string]string{"top": "high", "highonly": "high"}})
+	c.AddLast(EnvSource("SNAP"))
+	c.AddLast(FromLegacy(legacyMap{"legacyonly": "legacy", "shadow": "legacy"}))
+	c.AddLast(&MapSource{name: "low", items: map[string]string{"shadow": "low", "lowonly": "low", "empty": "", "top": "low"}})
+
+	tests := []struct {
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{"top", "high", true},
+		{"highonly", "high", true},
+		{"envonly", "env", true},
+		{"ENVONLY", "env", true},
+		{"shadow", "env", true},
+		{"legacyonly", "legacy", true},
+		{"lowonly", "low", true},
+		{"empty", "", true},
+		{"missing", "", false},
+	}
+	for _, tt := range tests {
+		v, ok := c.load().lookup(tt.key)
+		if v != tt.want || ok != tt.wantOK {
+			t.Errorf("lookup(%q) = %q, %v, want %q, %v", tt.key, v, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestSnapshotKeys(t *testing.T) {
+	c := NewConfig()
+	c.AddLast(&MapSource{name: "a", items: map[string]string{"db.host": "h", "db.slaves[0]": "s0", "dbx": "x"}})
+	c.AddLast(&MapSource{name: "b", items: map[string]string{"db.host": "h2", "db.port": "1", "app.name": "n"}})
+
+	if got, want := c.Keys(), []string{"app.name", "db.host", "db.port", "db.slaves[0]", "dbx"}; !equalStrings(got, want) {
+		t.Errorf("Keys() = %v, want %v", got, want)
+	}
+	if got, want := c.Sub("db").Keys(), []string{"host", "port", "slaves[0]"}; !equalStrings(got, want) {
+		t.Errorf("Sub(db).Keys() = %v, want %v", got, want)
+	}
+	if got, want := c.childKeys("db"), []string{"host", "port", "slaves"}; !equalStrings(got, want) {
+		t.Errorf("childKeys(db) = %v, want %v", got, want)
+	}
+	if got, want := c.childKeys(""), []string{"app", "db", "dbx"}; !equalStrings(got, want) {
+		t.Errorf("childKeys() = %v, want %v", got, want)
+	}
+
+	// 修改返回值不影响快照
+	keys := c.Keys()
+	keys[0] = "changed"
+	if c.Keys()[0] != "app.name" {
+		t.Error("修改Keys的返回值影响了快照")
+	}
+}
+
+func TestSnapshotValueCache(t *testing.T) {
+	c := NewConfig()
+	src := newTestSource(map[string]string{"port": "80", "timeout": "1s"})
+	c.AddLast(src)
+	defer c.Close()
+
+	if v := c.MustGetInt("port"); v != 80 {
+		t.Fatalf("port = %v, want 80", v)
+	}
+	if v := c.MustGetDuration("timeout"); v != time.Second {
+		t.Fatalf("timeout = %v, want 1s", v)
+	}
+
+	// 配置源更新后发布新的快照, 缓存随之失效
+	src.setItems(map[string]string{"port": "81", "timeout": "2s"})
+	src.notify(src)
+	if v := c.MustGetInt("port"); v != 81 {
+		t.Fatalf("更新后port = %v, want 81", v)
+	}
+	if v := c.MustGetDuration("timeout"); v != 2*time.Second {
+		t.Fatalf("更新后timeout = %v, want 2s", v)
+	}
+
+	// 同一个值按不同类型读取互不影响
+	if v := c.MustGetString("port"); v != "81" {
+		t.Fatalf("port = %q, want 81", v)
+	}
+	if _, err := c.GetBool("port"); err == nil {
+		t.Fatal("GetBool(port)应返回错误")
+	}
+}
+
+func TestSnapshotNoCacheForLiveSources(t *testing.T) {
+	m := legacyMap{"port": "80"}
+	c := NewConfig()
+	c.AddLast(FromLegacy(m))
+
+	if v := c.MustGetInt("port"); v != 80 {
+		t.Fatalf("port = %v, want 80", v)
+	}
+	m["port"] = "81"
+	if v := c.MustGetInt("port"); v != 81 {
+		t.Fatalf("不可冻结的配置源更新后port = %v, want 81", v)
+	}
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	c := NewConfig()
+	src := newTestSource(map[string]string{"a": "1"})
+	c.AddLast(src)
+	defer c.Close()
+
+	snap := c.Snapshot()
+	src.setItems(map[string]string{"a": "2"})
+	src.notify(src)
+
+	if v := snap.MustGetString("a"); v != "1" {
+		t.Errorf("快照中a = %q, want 1", v)
+	}
+	if v := c.MustGetString("a"); v != "2" {
+		t.Errorf("a = %q, want 2", v)
+	}
+}
+
+// 基准测试的配置: sources个配置源, 每个配置源keys个配置项, 模拟大的nacos配置
+func benchConfig(sources, keys int) *Config {
+	c := NewConfig()
+	for s := 0; s < sources; s++ {
+		items := make(map[string]string, keys)
+		for k := 0; k < keys; k++ {
+			items[fmt.Sprintf("svc.k%d", k)] = strconv.Itoa(s*keys + k)
+		}
+		items[fmt.Sprintf("svc.only%d", s)] = "1s"
+		c.AddLast(&MapSource{name: fmt.Sprintf("s%d", s), items: items})
+	}
+	return c
+}
+
+type benchStruct struct {
+	K0  int           `conf:"k0"`
+	K1  int           `conf:"k1"`
+	K2  string        `conf:"k2"`
+	K3  string        `conf:"k3"`
+	K4  int64         `conf:"k4"`
+	K5  uint          `conf:"k5"`
+	K6  float64       `conf:"k6"`
+	K7  string        `conf:"k7"`
+	K8  int           `conf:"k8"`
+	K9  int           `conf:"k9"`
+	K10 string        `conf:"k10"`
+	K11 string        `conf:"k11"`
+	K12 int           `conf:"k12"`
+	K13 int           `conf:"k13"`
+	K14 string        `conf:"k14"`
+	K15 string        `conf:"k15"`
+	Tm  time.Duration `conf:"only9"`
+	Mis string        `conf:"missing" default:"x"`
+}
+
+// linearLookup 引入索引之前的查找方式: 按优先级遍历配置源, 在每个配置源中遍历map比较key
+func linearLookup(sources []Source, key string) (string, bool) {
+	for _, s := range sources {
+		for k, v := range s.(*MapSource).items {
+			if k == key {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+func BenchmarkLookup(b *testing.B) {
+	c := benchConfig(10, 2000)
+	snap := c.load()
+	key := "svc.only9" // 只在优先级最低的配置源中
+
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, ok := linearLookup(snap.frozen, key); !ok {
+				b.Fatal("not found")
+			}
+		}
+	})
+	b.Run("perSource", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			found := false
+			for _, s := range snap.frozen {
+				if _, found = s.Lookup(key); found {
+					break
+				}
+			}
+			if !found {
+				b.Fatal("not found")
+			}
+		}
+	})
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, ok := snap.lookup(key); !ok {
+				b.Fatal("not found")
+			}
+		}
+	})
+}
+
+func BenchmarkGetLargeStruct(b *testing.B) {
+	c := benchConfig(10, 2000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var s benchStruct
+		if err := c.Get("svc", &s); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetTyped(b *testing.B) {
+	b.Run("GetInt", func(b *testing.B) {
+		c := benchConfig(10, 2000)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := c.GetInt("svc.k100"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("GetDuration", func(b *testing.B) {
+		c := benchConfig(10, 2000)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := c.GetDuration("svc.only9"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	// 包含不可冻结的配置源时不缓存, 每次重新查找和解析
+	b.Run("GetDurationUncached", func(b *testing.B) {
+		c := benchConfig(10, 2000)
+		c.AddFirst(FromLegacy(legacyMap{}))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := c.GetDuration("svc.only9"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -1,58 +1,202 @@
 package conf
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nacos-group/nacos-sdk-go/clients"
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
 	"github.com/nacos-group/nacos-sdk-go/common/constant"
 	"github.com/nacos-group/nacos-sdk-go/vo"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 )
 
+// Source 配置源
 type Source interface {
+	Name() string
+	// Lookup 获取配置项的值, 第二个返回值表示配置项是否存在, 存在的配置项的值可以是空字符串
+	Lookup(key string) (string, bool)
+}
+
+// LegacySource 旧的配置源接口, Get返回空字符串表示配置项不存在, 需要通过FromLegacy适配成Source
+type LegacySource interface {
 	Name() string
 	Get(key string) string
 }
 
+// FromLegacy 把旧的配置源适配成Source, 空字符串仍然表示配置项不存在
+// 如果s实现了Keys或者Watch和Close, 适配后的配置源同样支持列出配置项和监听变化
+func FromLegacy(s LegacySource) Source {
+	return &legacySource{LegacySource: s}
+}
+
+type legacySource struct {
+	LegacySource
+}
+
+func (s *legacySource) Lookup(key string) (string, bool) {
+	v := s.Get(key)
+	return v, v != ""
+}
+
+func (s *legacySource) Keys() []string {
+	if ks, ok := s.LegacySource.(interface{ Keys() []string }); ok {
+		return ks.Keys()
+	}
+	return nil
+}
+
+func (s *legacySource) Watch(fn func(Source)) {
+	if ws, ok := s.LegacySource.(interface{ Watch(func(Source)) }); ok {
+		ws.Watch(func(Source) {
+			fn(s)
+		})
+	}
+}
+
+func (s *legacySource) Close() error {
+	if c, ok := s.LegacySource.(interface{ Close() error }); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// KeysSource 可以列出全部配置项key的配置源, 绑定map等需要遍历子配置项的功能依赖于此接口
+type KeysSource interface {
+	Source
+	Keys() []string
+}
+
 type MapSource struct {
 	name  string
+	mu    sync.RWMutex
 	items map[string]string
 }
 
 func (s *MapSource) Name() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.name
 }
 
+func (s *MapSource) Lookup(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.items[key]
+	return v, ok
+}
+
+// Get 获取配置项的值, 不存在时返回空字符串
 func (s *MapSource) Get(key string) string {
-	for k, v := range s.items {
-		if k == key {
-			return v
-		}
+	v, _ := s.Lookup(key)
+	return v
+}
+
+// Keys 返回全部配置项的key
+func (s *MapSource) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.items))
+	for k := range s.items {
+		keys = append(keys, k)
 	}
-	return ""
+	return keys
 }
 
-// 创建YAML的配置源
+// setItems 替换全部配置项, 用于配置源重新加载, 返回配置项是否有变化
+func (s *MapSource) setItems(items map[string]string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if reflect.DeepEqual(s.items, items) {
+		return false
+	}
+	s.items = items
+	return true
+}
+
+// 创建YAML的配置源, 多文档的YAML中带on-profile的文档不会生效, 见NewYAMLSourceWithProfiles
 func NewYAMLSource(name string, data []byte) (Source, error) {
-	var mapSlice yaml.MapSlice
-	err := yaml.Unmarshal(data, &mapSlice)
+	return NewYAMLSourceWithProfiles(name, data, nil)
+}
+
+// NewYAMLSourceWithProfiles 创建YAML的配置源, 支持---分隔的多文档YAML, 后面文档的配置项覆盖前面的,
+// 带on-profile的文档只有在profiles包含on-profile的值时生效, on-profile可以是逗号分隔的多个profile
+// data可能来自远程等不可信的来源, 不处理include, include作为普通的配置项保留
+func NewYAMLSourceWithProfiles(name string, data []byte, profiles []string) (Source, error) {
+	items, err := parseYAMLProfiles(data, profiles)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, err
 	}
+	return &MapSource{name: name, items: items}, nil
+}
 
-	source := &MapSource{name: name, items: map[string]string{}}
+// parseYAML 解析YAML, 展开成key.sub[0]形式的配置项
+func parseYAML(data []byte) (map[string]string, error) {
+	return parseYAMLProfiles(data, nil)
+}
 
-	addEntry(source.items, "", mapSlice)
+// parseYAMLProfiles 解析多文档的YAML, 合并没有on-profile的文档和on-profile被激活的文档
+func parseYAMLProfiles(data []byte, profiles []string) (map[string]string, error) {
+	items := map[string]string{}
 
-	return source, nil
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var mapSlice yaml.MapSlice
+		err := d.Decode(&mapSlice)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		doc := map[string]string{}
+		addEntry(doc, "", mapSlice)
+
+		if on, ok := doc[onProfileKey]; ok {
+			if !profileActive(on, profiles) {
+				continue
+			}
+			delete(doc, onProfileKey)
+		}
+		mergeItems(items, doc)
+	}
+
+	return items, nil
+}
+
+// mergeItems 把src的配置项合并到dst, src中的列表整体替换dst中同名的列表
+func mergeItems(dst, src map[string]string) {
+	for k := range src {
+		if i := strings.IndexByte(k, '['); i > 0 {
+			list := k[:i] + "["
+			for dk := range dst {
+				if strings.HasPrefix(dk, list) {
+					if _, ok := src[dk]; !ok {
+						delete(dst, dk)
+					}
+				}
+			}
+		}
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
 }
 
 func addEntry(entries map[string]string, keyPrefix string, v interface{}) {
@@ -64,70 +208,204 @@ func addEntry(entries map[string]string, keyPrefix string, v interface{}) {
 		for _, m := range v.(yaml.MapSlice) {
 			addEntry(entries, fmt.Sprintf("%s%v", keyPrefix, m.Key), m.Value)
 		}
+	case map[string]interface{}:
+		if keyPrefix != "" {
+			keyPrefix += "."
+		}
+		for k, m := range v.(map[string]interface{}) {
+			addEntry(entries, keyPrefix+k, m)
+		}
 	case []interface{}:
 		for i, s := range v.([]interface{}) {
 			addEntry(entries, fmt.Sprintf("%s[%d]", keyPrefix, i), s)
 		}
+	case []map[string]interface{}:
+		for i, s := range v.([]map[string]interface{}) {
+			addEntry(entries, fmt.Sprintf("%s[%d]", keyPrefix, i), s)
+		}
+	case time.Time:
+		entries[keyPrefix] = v.(time.Time).Format(time.RFC3339Nano)
+	case nil:
+		// YAML的null作为显式配置的空值
+		entries[keyPrefix] = ""
 	default:
 		entries[keyPrefix] = fmt.Sprintf("%v", v)
 	}
 }
 
-// 创建文件配置源
+// 创建文件配置源, 根据文件后缀(.json, .toml, .properties, 其他为YAML)选择解析格式
+// 文件中的include配置项可以引入其他配置文件, 见includeItems
+// 返回的配置源实现了WatchableSource, 文件或include的文件修改后会重新加载
 func FileSource(file string) (Source, error) {
-	data, err := ioutil.ReadFile(file)
+	return FileSourceWithProfiles(file, nil)
+}
+
+// FileSourceWithProfiles 创建文件配置源, YAML文件中带on-profile的文档按profiles激活
+func FileSourceWithProfiles(file string, profiles []string) (Source, error) {
+	return newFileSource(osFS{}, "file:"+file, file, profiles)
+}
+
+// newFileSource 创建fsys中的文件配置源, 轮询文件和include的文件的修改时间
+func newFileSource(fsys fs.FS, name, file string, profiles []string) (Source, error) {
+	items, modTimes, err := loadFile(fsys, file, profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newPollSource(name, items)
+	s.reload = func() (map[string]string, bool, error) {
+		// 文件本身或include的文件有修改时重新加载
+		changed, err := modTimesChanged(fsys, modTimes)
+		if err != nil || !changed {
+			return nil, false, err
+		}
+		items, mts, err := loadFile(fsys, file, profiles)
+		if err != nil {
+			return nil, false, err
+		}
+		modTimes = mts
+		return items, true, nil
+	}
+	return s, nil
+}
+
+// modTimesChanged 文件的修改时间是否与modTimes中记录的不同
+func modTimesChanged(fsys fs.FS, modTimes map[string]time.Time) (bool, error) {
+	for f, mt := range modTimes {
+		info, err := fs.Stat(fsys, f)
+		if err != nil {
+			return false, errors.Wrap(err, "读取文件配置错误")
+		}
+		if !info.ModTime().Equal(mt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// loadFile 加载配置文件和include的文件, 返回配置项和所有涉及的文件的修改时间
+func loadFile(fsys fs.FS, file string, profiles []string) (map[string]string, map[string]time.Time, error) {
+	modTimes := map[string]time.Time{}
+	items, err := loadFileIncludes(fsys, file, profiles, nil, modTimes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, modTimes, nil
+}
+
+// loadFileIncludes 加载配置文件, 根据文件后缀选择解析格式, stack是正在加载的include链
+func loadFileIncludes(fsys fs.FS, file string, profiles []string, stack []string, modTimes map[string]time.Time) (map[string]string, error) {
+	info, err := fs.Stat(fsys, file)
 	if err != nil {
 		return nil, errors.Wrap(err, "创建文件配置错误")
 	}
-	return NewYAMLSource("file:"+file, data)
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, errors.Wrap(err, "创建文件配置错误")
+	}
+	var items map[string]string
+	if format := formatOf(file); format == FormatYAML {
+		items, err = parseYAMLProfiles(data, profiles)
+	} else {
+		items, err = parseContent(format, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	modTimes[file] = info.ModTime()
+	return includeItems(fsys, items, dirPath(fsys, file), profiles, append(stack, file), modTimes)
 }
 
-// 创建apollo配置源
+// 创建apollo配置源, 根据namespace的格式解析配置, 没有后缀的namespace为properties格式
+// 返回的配置源实现了WatchableSource, 通过apollo的长轮询通知接口监听配置发布
+// 设置了SetCacheDir时, apollo不可用会使用本地缓存
 func ApolloSource(server, app, env, ns string) (Source, error) {
-	config, err := apolloConfig(server, app, env, ns)
+	name := fmt.Sprintf("apollo-%v-%v-%v", app, env, ns)
+	body, cached, err := loadRemote(name, func() ([]byte, error) {
+		return apolloConfig(context.Background(), server, app, env, ns)
+	})
 	if err != nil {
 		return nil, err
 	}
-	return NewYAMLSource(fmt.Sprintf("apollo-%v-%v-%v", app, env, ns), []byte(config))
+	items, err := parseApollo(ns, body)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newApolloSource(name, server, app, env, ns, items)
+	s.markCached(cached)
+	return s, nil
 }
 
-func apolloConfig(server, app, env, ns string) (string, error) {
+func apolloConfig(ctx context.Context, server, app, env, ns string) ([]byte, error) {
 	url := fmt.Sprintf(server+"/configfiles/json/%s/%s/%s", app, env, ns)
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return "", errors.Wrapf(err, "apollo配置获取错误, url: %v", url)
+		return nil, errors.Wrapf(err, "apollo配置获取错误, url: %v", url)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "apollo配置获取错误, url: %v", url)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return "", errors.Errorf("apollo配置获取错误, url: %v, http status code: %v", url, resp.StatusCode)
+		return nil, errors.Errorf("apollo配置获取错误, url: %v, http status code: %v", url, resp.StatusCode)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", errors.Wrap(err, "apollo配置读取错误")
+		return nil, errors.Wrap(err, "apollo配置读取错误")
 	}
+	return body, nil
+}
 
+// parseApollo 解析apollo返回的json, 没有后缀的namespace为properties格式, json就是全部配置项,
+// 其他格式的配置内容在content属性中
+func parseApollo(ns string, body []byte) (map[string]string, error) {
 	var result map[string]interface{}
-	if err = json.Unmarshal(body, &result); err != nil {
-		return "", errors.Wrap(err, "apollo配置json解析错误")
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, errors.Wrap(err, "apollo配置json解析错误")
+	}
+
+	format := formatOf(ns)
+	if path.Ext(ns) == "" {
+		format = FormatProperties
+	}
+	if format == FormatProperties {
+		items := map[string]string{}
+		for k, v := range result {
+			items[k] = fmt.Sprintf("%v", v)
+		}
+		return items, nil
 	}
 
 	if content, ok := result["content"]; ok {
 		if s, ok := content.(string); ok {
-			return s, nil
+			return parseContent(format, []byte(s))
 		}
-		return "", errors.New("apollo配置json解析错误, content属性错误")
+		return nil, errors.New("apollo配置json解析错误, content属性错误")
 	} else {
 		if msg, ok := result["message"]; ok {
-			return "", errors.Errorf("apollo配置获取错误: %v", msg)
+			return nil, errors.Errorf("apollo配置获取错误: %v", msg)
 		}
-		return "", errors.Errorf("apollo配置获取错误, 内容: %v", result)
+		return nil, errors.Errorf("apollo配置获取错误, 内容: %v", result)
 	}
 }
 
-// 创建命令行配置
+// 创建命令行配置, 从os.Args读取
+// 没有声明的参数, 参数格式见parseArgs; 需要声明参数, 帮助信息或位置参数时使用FlagSet
 func CMDLineSource() Source {
+	return CMDLineSourceFromArgs(os.Args[1:])
+}
+
+// CMDLineSourceFromArgs 从args(不包含程序名)创建命令行配置
+func CMDLineSourceFromArgs(args []string) Source {
+	return &MapSource{name: "cmd", items: parseArgs(args)}
+}
+
+// parseArgs 解析没有声明的命令行参数, 支持-key=value, --key value, 后面没有值的-key当作true
+func parseArgs(args []string) map[string]string {
 	items := map[string]string{}
 	key := ""
 
@@ -171,32 +449,87 @@ func CMDLineSource() Source {
 		}
 	}
 
-	for _, arg := range os.Args[1:] {
-		startArg(arg)
+	for _, arg := range args {
+		if arg != "" {
+			startArg(arg)
+		}
 	}
 
 	if key != "" {
 		items[key] = "true"
 	}
 
-	return &MapSource{name: "cmd", items: items}
+	return items
 }
-// NacosSource 创建nacos的配置源
+
+// NacosSource 创建nacos的配置源, 根据dataId的后缀选择解析格式, 返回的配置源实现了WatchableSource, 通过ListenConfig监听配置发布
+// 设置了SetCacheDir时, nacos不可用会使用本地缓存
 func NacosSource(nacosUrl, namespaceId, dataId, group, username, password string) (Source, error) {
-	source, err := nacosConfig(nacosUrl, namespaceId, dataId, group, username, password)
+	client, err := nacosClient(nacosUrl, namespaceId, username, password)
 	if err != nil {
 		return nil, err
 	}
-	return NewYAMLSource(fmt.Sprintf("nacos-%v-%v-%v", namespaceId, dataId, group), []byte(source))
+
+	name := fmt.Sprintf("nacos-%v-%v-%v", namespaceId, dataId, group)
+	fetch := func() ([]byte, error) {
+		content, err := client.GetConfig(vo.ConfigParam{
+			DataId: dataId,
+			Group:  group,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "nacos 获取配置失败, nacosUrl: %v, namespace: %v, dataId: %v, group: %v", nacosUrl, namespaceId, dataId, group)
+		}
+		return []byte(content), nil
+	}
+	content, cached, err := loadRemote(name, fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	format := formatOf(dataId)
+	items, err := parseContent(format, content)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &nacosSource{
+		MapSource: &MapSource{name: name, items: items},
+		client:    client,
+		dataId:    dataId,
+		group:     group,
+		format:    format,
+		cacheName: name,
+	}
+	s.markCached(cached)
+
+	err = client.ListenConfig(vo.ConfigParam{
+		DataId:   dataId,
+		Group:    group,
+		OnChange: s.onChange,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "nacos 监听配置失败, nacosUrl: %v, namespace: %v, dataId: %v, group: %v", nacosUrl, namespaceId, dataId, group)
+	}
+
+	// 使用缓存创建时在后台重新获取
+	if cached {
+		go func() {
+			if content, err := fetch(); err == nil {
+				s.onChange(namespaceId, group, dataId, string(content))
+			}
+		}()
+	}
+
+	return s, nil
 }
 
-func nacosConfig(nacosUrl, namespaceId, dataId, group, username, password string) (string, error) {
+func nacosClient(nacosUrl, namespaceId, username, password string) (config_client.IConfigClient, error) {
 	url, err := url.Parse(nacosUrl)
 
 	if err != nil {
-		return "", errors.Wrapf(err, "nacos地址解析错误, url: %v", nacosUrl)
+		return nil, errors.Wrapf(err, "nacos地址解析错误, url: %v", nacosUrl)
 	}
-	
+
 	port := 80
 	host := url.Host
 
@@ -206,16 +539,16 @@ func nacosConfig(nacosUrl, namespaceId, dataId, group, username, password string
 		host = temp[0]
 		port, err = strconv.Atoi(temp[1])
 		if err != nil {
-			return "", errors.Wrapf(err, "nacos地址端口解析错误, url: %v", nacosUrl)
+			return nil, errors.Wrapf(err, "nacos地址端口解析错误, url: %v", nacosUrl)
 		}
 	}
 
 	scs := []constant.ServerConfig{
 		{
-			IpAddr: host,
-			Port: uint64(port),
+			IpAddr:      host,
+			Port:        uint64(port),
 			ContextPath: url.Path,
-			Scheme: url.Scheme,
+			Scheme:      url.Scheme,
 		},
 	}
 
@@ -223,8 +556,8 @@ func nacosConfig(nacosUrl, namespaceId, dataId, group, username, password string
 		NamespaceId:         namespaceId, //namespace id
 		TimeoutMs:           5000,
 		NotLoadCacheAtStart: true,
-		Username: username,
-		Password: password,
+		Username:            username,
+		Password:            password,
 	}
 
 	client, err := clients.CreateConfigClient(map[string]interface{}{
@@ -233,17 +566,45 @@ func nacosConfig(nacosUrl, namespaceId, dataId, group, username, password string
 	})
 
 	if err != nil {
-		return "", errors.Wrapf(err, "nacos config client创建失败, nacosUrl: %v, namespace: %v", nacosUrl, namespaceId)
+		return nil, errors.Wrapf(err, "nacos config client创建失败, nacosUrl: %v, namespace: %v", nacosUrl, namespaceId)
 	}
 
-	content, err := client.GetConfig(vo.ConfigParam{
-		DataId: dataId,
-		Group:  group,
-	})
+	return client, nil
+}
+
+// nacosSource 保持nacos client并监听配置变化的配置源
+type nacosSource struct {
+	*MapSource
+	notifier
+	client config_client.IConfigClient
+	dataId string
+	group  string
+	format string
 
+	cacheName string
+}
+
+func (s *nacosSource) onChange(namespace, group, dataId, data string) {
+	items, err := parseContent(s.format, []byte(data))
 	if err != nil {
-		return "", errors.Wrapf(err, "nacos 获取配置失败, nacosUrl: %v, namespace: %v, dataId: %v, group: %v", nacosUrl, namespaceId, dataId, group)
+		fmt.Printf("nacos配置解析错误, %v: %v\n", s.Name(), err)
+		return
 	}
+	writeCache(s.cacheName, []byte(data))
+	changed := s.setItems(items)
+	if s.markCached(false) || changed {
+		s.notify(s)
+	}
+}
 
-	return content, nil
-}
\ No newline at end of file
+// Close 取消nacos的配置监听
+func (s *nacosSource) Close() error {
+	err := s.client.CancelListenConfig(vo.ConfigParam{
+		DataId: s.dataId,
+		Group:  s.group,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "nacos 取消监听配置失败, dataId: %v, group: %v", s.dataId, s.group)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package conf
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// URLTimeout URLSource没有设置Timeout时单次请求的超时
+var URLTimeout = 10 * time.Second
+
+// URLOptions URLSource的选项
+type URLOptions struct {
+	// Format 配置格式, 为空时根据url路径的后缀判断, 无法判断时为YAML
+	Format string
+	// Header 每次请求附加的请求头, 例如Authorization: Bearer xxx
+	Header http.Header
+	// Username, Password 不为空时使用basic认证
+	Username string
+	Password string
+	// Timeout 单次请求的超时, 为0时使用URLTimeout
+	Timeout time.Duration
+	// Interval 检查更新的间隔, 为0时使用PollInterval
+	Interval time.Duration
+}
+
+// urlFetcher 获取url的内容, 记录ETag和Last-Modified用于条件请求
+type urlFetcher struct {
+	url string
+	// redacted 隐藏了密码的url, 用于错误信息和日志
+	redacted string
+	opts     URLOptions
+	client   *http.Client
+
+	etag         string
+	lastModified string
+}
+
+// fetch 请求url, conditional为true时带上If-None-Match和If-Modified-Since, 第二个返回值表示内容没有变化
+func (f *urlFetcher) fetch(conditional bool) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "url配置获取错误, url: %v", f.redacted)
+	}
+	for k, vs := range f.opts.Header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	if f.opts.Username != "" || f.opts.Password != "" {
+		req.SetBasicAuth(f.opts.Username, f.opts.Password)
+	}
+	if conditional {
+		if f.etag != "" {
+			req.Header.Set("If-None-Match", f.etag)
+		}
+		if f.lastModified != "" {
+			req.Header.Set("If-Modified-Since", f.lastModified)
+		}
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "url配置获取错误, url: %v", f.redacted)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && conditional {
+		return nil, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, errors.Errorf("url配置获取错误, url: %v, http status code: %v", f.redacted, resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "url配置读取错误, url: %v", f.redacted)
+	}
+	f.etag = resp.Header.Get("ETag")
+	f.lastModified = resp.Header.Get("Last-Modified")
+	return body, false, nil
+}
+
+// URLSource 创建通过http(s)获取的配置源, 返回的配置源实现了WatchableSource,
+// 按Interval轮询, 使用ETag和Last-Modified做条件请求, 服务端返回304时不重新解析
+// 设置了SetCacheDir时, url不可用会使用本地缓存
+func URLSource(rawURL string, opts URLOptions) (Source, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "url配置地址错误: %v", rawURL)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("url配置地址只支持http和https: %v", u.Redacted())
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = formatOf(u.Path)
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = URLTimeout
+	}
+	f := &urlFetcher{url: rawURL, redacted: u.Redacted(), opts: opts, client: &http.Client{Timeout: timeout}}
+
+	name := "url:" + u.Redacted()
+	data, cached, err := loadRemote(name, func() ([]byte, error) {
+		data, _, err := f.fetch(false)
+		return data, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	items, err := parseContent(format, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "url配置解析错误, url: %v", u.Redacted())
+	}
+
+	s := newPollSource(name, items)
+	s.markCached(cached)
+	if opts.Interval > 0 {
+		s.interval = opts.Interval
+	}
+	s.reload = func() (map[string]string, bool, error) {
+		// 内容来自缓存时没有ETag, 会完整请求一次
+		data, notModified, err := f.fetch(true)
+		if err != nil || notModified {
+			return nil, false, err
+		}
+		items, err := parseContent(format, data)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "url配置解析错误, url: %v", u.Redacted())
+		}
+		writeCache(name, data)
+		return items, true, nil
+	}
+	return s, nil
+}
+
+// AddURLSource 添加通过http(s)获取的配置, 最低优先级, 见URLSource
+func (c *Config) AddURLSource(rawURL string, opts URLOptions) error {
+	s, err := URLSource(rawURL, opts)
+	if err != nil {
+		return err
+	}
+	c.AddLast(s)
+	return nil
+}
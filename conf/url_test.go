@@ -0,0 +1,169 @@
+package conf
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeURL 提供配置内容的http服务, 支持ETag和Last-Modified条件请求
+type fakeURL struct {
+	mu       sync.Mutex
+	body     string
+	version  int
+	requests []*http.Request
+	ok       int // 返回200的次数
+}
+
+func (f *fakeURL) set(body string) {
+	f.mu.Lock()
+	f.body = body
+	f.version++
+	f.mu.Unlock()
+}
+
+func (f *fakeURL) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r)
+
+	etag := fmt.Sprintf(`"v%d"`, f.version)
+	modified := time.Date(2020, 1, 1, 0, f.version, 0, 0, time.UTC).Format(http.TimeFormat)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	f.ok++
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified)
+	fmt.Fprint(w, f.body)
+}
+
+func (f *fakeURL) stats() (requests []*http.Request, ok int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*http.Request(nil), f.requests...), f.ok
+}
+
+func TestURLSource(t *testing.T) {
+	fake := &fakeURL{body: "db:\n  host: a\n"}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	s, err := URLSource(srv.URL+"/app.yaml", URLOptions{
+		Header:   http.Header{"Authorization": {"Bearer t"}},
+		Username: "u",
+		Password: "p",
+		Interval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewConfig()
+	c.AddLast(s)
+	defer c.Close()
+
+	changes := make(chan string, 10)
+	c.OnChange("db.host", func(old, new string) {
+		changes <- fmt.Sprintf("%v->%v", old, new)
+	})
+	if v := c.MustGetString("db.host"); v != "a" {
+		t.Fatalf("db.host = %q, want a", v)
+	}
+
+	// 轮询带上条件请求头, 内容没有变化时返回304, 不重新解析也不触发回调
+	waitFor(t, "304", func() bool {
+		requests, _ := fake.stats()
+		return len(requests) >= 3
+	})
+	requests, ok := fake.stats()
+	if ok != 1 {
+		t.Fatalf("内容没有变化时返回了%v次200, want 1", ok)
+	}
+	if h := requests[0].Header; h.Get("If-None-Match") != "" || h.Get("If-Modified-Since") != "" {
+		t.Errorf("第一次请求不应该是条件请求: %v", h)
+	}
+	for _, r := range requests {
+		if user, pass, _ := r.BasicAuth(); user != "u" || pass != "p" {
+			t.Errorf("basic认证 = %q, %q, want u, p", user, pass)
+		}
+		if h := r.Header.Get("Authorization"); !strings.HasPrefix(h, "Basic ") {
+			t.Errorf("Authorization = %q, basic认证应覆盖Header中的同名请求头", h)
+		}
+	}
+	for _, r := range requests[1:] {
+		if h := r.Header.Get("If-None-Match"); h != `"v0"` {
+			t.Errorf("If-None-Match = %q, want \"v0\"", h)
+		}
+		if h := r.Header.Get("If-Modified-Since"); h == "" {
+			t.Error("轮询没有带If-Modified-Since")
+		}
+	}
+	select {
+	case ch := <-changes:
+		t.Fatalf("配置没有变化时触发了回调: %v", ch)
+	default:
+	}
+
+	// 内容变化后重新解析并触发回调
+	fake.set("db:\n  host: b\n")
+	select {
+	case ch := <-changes:
+		if ch != "a->b" {
+			t.Fatalf("change = %v, want a->b", ch)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("内容变化后没有触发回调")
+	}
+	if v := c.MustGetString("db.host"); v != "b" {
+		t.Fatalf("db.host = %q, want b", v)
+	}
+	waitFor(t, "使用新的ETag", func() bool {
+		requests, _ := fake.stats()
+		return requests[len(requests)-1].Header.Get("If-None-Match") == `"v1"`
+	})
+}
+
+func TestURLSourceHeader(t *testing.T) {
+	fake := &fakeURL{body: `{"a": 1}`}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	s, err := URLSource(srv.URL+"/config", URLOptions{
+		Format: FormatJSON,
+		Header: http.Header{"Authorization": {"Bearer t"}, "X-Env": {"prod"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := s.Lookup("a"); v != "1" {
+		t.Errorf("a = %q, want 1", v)
+	}
+	requests, _ := fake.stats()
+	if h := requests[0].Header; h.Get("Authorization") != "Bearer t" || h.Get("X-Env") != "prod" {
+		t.Errorf("请求头 = %v", h)
+	}
+}
+
+func TestURLSourceRedactsPassword(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	u := strings.Replace(srv.URL, "http://", "http://user:s3cret@", 1) + "/bad.yaml"
+	_, err := URLSource(u, URLOptions{})
+	if err == nil {
+		t.Fatal("404应返回错误")
+	}
+	if strings.Contains(err.Error(), "s3cret") || !strings.Contains(err.Error(), "404") {
+		t.Errorf("错误信息 = %v", err)
+	}
+
+	srv.Close()
+	if _, err := URLSource(u, URLOptions{}); err == nil || strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("错误信息 = %v", err)
+	}
+}
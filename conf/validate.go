@@ -0,0 +1,226 @@
+package conf
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Violation 不符合校验规则的配置项
+type Violation struct {
+	Key  string
+	Rule string
+	Msg  string
+}
+
+// ValidationErr 配置校验错误, 包含所有不符合校验规则的配置项
+type ValidationErr struct {
+	Violations []Violation
+	// 没有找到任何配置项时的NotFoundErr
+	missing error
+}
+
+func (e ValidationErr) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = fmt.Sprintf("%s: %s", v.Key, v.Msg)
+	}
+	return "config validation failed: " + strings.Join(msgs, "; ")
+}
+
+// 是否为配置校验错误
+func Invalid(err error) bool {
+	_, ok := err.(ValidationErr)
+	return ok
+}
+
+// collectViolations 收集下一级绑定产生的校验错误, 使校验继续进行
+// err是ValidationErr时返回其中的NotFoundErr或nil, 下一级没有任何配置项时, keepMissing为false则忽略校验错误,
+// 例如slice越界的元素不需要校验
+func collectViolations(violations *[]Violation, err error, keepMissing bool) error {
+	ve, ok := err.(ValidationErr)
+	if !ok {
+		return err
+	}
+	if ve.missing == nil || keepMissing {
+		*violations = append(*violations, ve.Violations...)
+	}
+	return ve.missing
+}
+
+// violationsOr 有校验错误时返回ValidationErr, 否则返回nfe
+func violationsOr(violations []Violation, nfe error) error {
+	if len(violations) > 0 {
+		return ValidationErr{Violations: violations, missing: nfe}
+	}
+	return nfe
+}
+
+// validateField 按validate标签校验字段, 规则用,分隔:
+//
+//	required      必须配置, 并且字符串不能为空
+//	min=n, max=n  数字的取值范围, 字符串的长度范围, slice/map的元素个数范围
+//	oneof=a b c   必须是列出的值之一, 用空格分隔
+//	url           必须是带scheme和host的url
+//	hostport      必须是host:port
+//	regexp=expr   必须匹配正则表达式, 由于表达式中可能包含逗号, regexp必须是最后一条规则
+//
+// 除required外, 字段没有配置时不校验
+func validateField(key, rules string, v reflect.Value, found bool) ([]Violation, error) {
+	var violations []Violation
+	violate := func(rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Key: key, Rule: rule, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	for rules != "" {
+		rule := rules
+		if strings.HasPrefix(rule, "regexp=") {
+			rules = ""
+		} else if i := strings.IndexByte(rules, ','); i >= 0 {
+			rule, rules = rules[:i], rules[i+1:]
+		} else {
+			rules = ""
+		}
+
+		name, arg := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+		name = strings.TrimSpace(name)
+
+		if name == "required" {
+			if !found || (v.Kind() == reflect.String && v.String() == "") {
+				violate(name, "必须配置")
+			}
+			continue
+		}
+		if !found {
+			continue
+		}
+
+		rv := v
+		for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+			if rv.IsNil() {
+				break
+			}
+			rv = rv.Elem()
+		}
+
+		switch name {
+		case "min", "max":
+			limit, err := parseLimit(rv, arg)
+			if err != nil {
+				return nil, errors.Errorf("校验规则错误: %s with key: %s", rule, key)
+			}
+			n, what, ok := measure(rv)
+			if !ok {
+				return nil, errors.Errorf("校验规则%s不支持类型: %v with key: %s", name, v.Type(), key)
+			}
+			if name == "min" && n < limit {
+				violate(name, "%s不能小于%v", what, arg)
+			}
+			if name == "max" && n > limit {
+				violate(name, "%s不能大于%v", what, arg)
+			}
+		case "oneof":
+			s := stringOf(rv)
+			match := false
+			for _, o := range strings.Fields(arg) {
+				if o == s {
+					match = true
+					break
+				}
+			}
+			if !match {
+				violate(name, "值%s必须是[%s]之一", s, arg)
+			}
+		case "url":
+			s := stringOf(rv)
+			if u, err := url.Parse(s); err != nil || u.Scheme == "" || u.Host == "" {
+				violate(name, "值%s不是有效的url", s)
+			}
+		case "hostport":
+			s := stringOf(rv)
+			_, port, err := net.SplitHostPort(s)
+			if err == nil {
+				_, err = strconv.ParseUint(port, 10, 16)
+			}
+			if err != nil {
+				violate(name, "值%s不是有效的host:port", s)
+			}
+		case "regexp":
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, errors.Wrapf(err, "校验规则错误: %s with key: %s", rule, key)
+			}
+			s := stringOf(rv)
+			if !re.MatchString(s) {
+				violate(name, "值%s不匹配%s", s, arg)
+			}
+		default:
+			return nil, errors.Errorf("不支持的校验规则: %s with key: %s", rule, key)
+		}
+	}
+
+	return violations, nil
+}
+
+// stringOf 返回oneof, url, hostport和regexp校验的字符串, 优先使用encoding.TextMarshaler和fmt.Stringer,
+// 方法的接收者可以是指针, 例如url.URL的String
+func stringOf(v reflect.Value) string {
+	if !v.CanAddr() {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v = p.Elem()
+	}
+	switch m := v.Addr().Interface().(type) {
+	case encoding.TextMarshaler:
+		if b, err := m.MarshalText(); err == nil {
+			return string(b)
+		}
+	case fmt.Stringer:
+		return m.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// parseLimit 解析min/max的参数, time.Duration和ByteSize类型的参数可以带单位, 例如min=1s, max=1GB
+func parseLimit(v reflect.Value, arg string) (float64, error) {
+	switch v.Type() {
+	case durationType:
+		d, err := time.ParseDuration(arg)
+		return float64(d), err
+	case byteSizeType:
+		b, err := ParseByteSize(arg)
+		return float64(b), err
+	default:
+		return strconv.ParseFloat(arg, 64)
+	}
+}
+
+// measure 返回min/max比较的数值
+func measure(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "值", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "值", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "值", true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "长度", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), "元素个数", true
+	default:
+		return 0, "", false
+	}
+}
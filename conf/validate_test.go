@@ -0,0 +1,80 @@
+package conf
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type validateConfig struct {
+	Name     string        `validate:"required,min=2,max=5"`
+	Mode     string        `validate:"oneof=dev prod"`
+	Port     int           `validate:"min=1,max=65535"`
+	Endpoint url.URL       `validate:"url"`
+	Backup   *url.URL      `validate:"url,regexp=^https://"`
+	Addr     string        `validate:"hostport"`
+	Timeout  time.Duration `validate:"min=1s,oneof=1s 2s"`
+	Size     ByteSize      `validate:"max=1MB,regexp=B$"`
+	Tags     []string      `validate:"max=2"`
+}
+
+func TestValidate(t *testing.T) {
+	valid := map[string]string{
+		"v.name":     "app",
+		"v.mode":     "prod",
+		"v.port":     "8080",
+		"v.endpoint": "http://h/p",
+		"v.backup":   "https://b/p",
+		"v.addr":     "localhost:80",
+		"v.timeout":  "2s",
+		"v.size":     "512KB",
+		"v.tags[0]":  "a",
+	}
+	tests := []struct {
+		name  string
+		items map[string]string
+		rules []string
+	}{
+		{name: "合法"},
+		{name: "required", items: map[string]string{"v.name": ""}, rules: []string{"name:required", "name:min"}},
+		{name: "长度", items: map[string]string{"v.name": "abcdef"}, rules: []string{"name:max"}},
+		{name: "oneof", items: map[string]string{"v.mode": "test"}, rules: []string{"mode:oneof"}},
+		{name: "范围", items: map[string]string{"v.port": "70000"}, rules: []string{"port:max"}},
+		{name: "url.URL", items: map[string]string{"v.endpoint": "/p"}, rules: []string{"endpoint:url"}},
+		{name: "*url.URL", items: map[string]string{"v.backup": "http://b/p"}, rules: []string{"backup:regexp"}},
+		{name: "hostport", items: map[string]string{"v.addr": "localhost:99999"}, rules: []string{"addr:hostport"}},
+		{name: "Duration", items: map[string]string{"v.timeout": "500ms"}, rules: []string{"timeout:min", "timeout:oneof"}},
+		{name: "ByteSize", items: map[string]string{"v.size": "2MB"}, rules: []string{"size:max"}},
+		{name: "元素个数", items: map[string]string{"v.tags[1]": "b", "v.tags[2]": "c"}, rules: []string{"tags:max"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := map[string]string{}
+			for k, v := range valid {
+				items[k] = v
+			}
+			for k, v := range tt.items {
+				items[k] = v
+			}
+			c := NewConfig()
+			c.AddLast(&MapSource{name: "m", items: items})
+
+			var v validateConfig
+			err := c.Get("v", &v)
+			var rules []string
+			if err != nil {
+				ve, ok := err.(ValidationErr)
+				if !ok {
+					t.Fatalf("Get() error = %v", err)
+				}
+				for _, viol := range ve.Violations {
+					rules = append(rules, viol.Key[len("v."):]+":"+viol.Rule)
+				}
+			}
+			if !reflect.DeepEqual(rules, tt.rules) {
+				t.Errorf("violations = %q, want %q (%v)", rules, tt.rules, err)
+			}
+		})
+	}
+}
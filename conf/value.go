@@ -0,0 +1,32 @@
+package conf
+
+import "fmt"
+
+// Value 获取配置项并转换为T, 支持Get可以转换的所有类型
+// 配置项不存在时返回零值和NotFoundErr, 转换或校验失败时返回零值和错误
+func Value[T any](c *Config, key string) (T, error) {
+	var v T
+	if err := c.Get(key, &v); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+// ValueOr 与Value一样, 除了配置项不存在时返回def和nil
+func ValueOr[T any](c *Config, key string, def T) (T, error) {
+	v, err := Value[T](c, key)
+	if err != nil && NotFound(err) {
+		return def, nil
+	}
+	return v, err
+}
+
+// MustValue 与Value一样, 除了遇到错误会panic
+func MustValue[T any](c *Config, key string) T {
+	v, err := Value[T](c, key)
+	if err != nil {
+		panic(fmt.Sprintf("config err: %v\n%+v", key, err))
+	}
+	return v
+}
@@ -0,0 +1,104 @@
+package conf
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PollInterval 轮询类配置源(如文件)检查变化的间隔
+var PollInterval = 2 * time.Second
+
+// WatchableSource 可以监听变化的配置源
+type WatchableSource interface {
+	Source
+	// Watch 注册回调, 配置源的内容更新后调用
+	Watch(fn func(Source))
+	// Close 停止监听, 释放资源
+	Close() error
+}
+
+// notifier 管理配置源变化的回调
+type notifier struct {
+	mu  sync.Mutex
+	fns []func(Source)
+}
+
+func (n *notifier) Watch(fn func(Source)) {
+	n.mu.Lock()
+	n.fns = append(n.fns, fn)
+	n.mu.Unlock()
+}
+
+func (n *notifier) notify(s Source) {
+	n.mu.Lock()
+	fns := make([]func(Source), len(n.fns))
+	copy(fns, n.fns)
+	n.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(s)
+	}
+}
+
+// pollSource 定时调用reload检查并重新加载的配置源
+type pollSource struct {
+	*MapSource
+	notifier
+	interval time.Duration
+	// reload 返回新的配置项, 第二个返回值表示是否有变化
+	reload func() (map[string]string, bool, error)
+
+	start sync.Once
+	stop  sync.Once
+	done  chan struct{}
+}
+
+func newPollSource(name string, items map[string]string) *pollSource {
+	return &pollSource{
+		MapSource: &MapSource{name: name, items: items},
+		interval:  PollInterval,
+		done:      make(chan struct{}),
+	}
+}
+
+// Watch 注册回调, 第一次注册时开始轮询
+func (s *pollSource) Watch(fn func(Source)) {
+	s.notifier.Watch(fn)
+	s.start.Do(func() {
+		go s.poll()
+	})
+}
+
+func (s *pollSource) Close() error {
+	s.stop.Do(func() {
+		close(s.done)
+	})
+	return nil
+}
+
+func (s *pollSource) poll() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			items, changed, err := s.reload()
+			if err != nil {
+				fmt.Printf("配置源重新加载错误, %v: %v\n", s.Name(), err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			// 内容重新加载成功后不再是本地缓存
+			updated := s.setItems(items)
+			if s.markCached(false) || updated {
+				s.notify(s)
+			}
+		}
+	}
+}